@@ -0,0 +1,284 @@
+package fresh
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// AccessLog config, format is common, combined, json or a template made of Apache directives
+	AccessLog struct {
+		once    sync.Once
+		mu      sync.Mutex
+		out     io.Writer
+		file    *rotateWriter
+		Format  string    `yaml:"format,omitempty"`
+		Sample  float64   `yaml:"sample,omitempty"`  // fraction of the requests logged, all when zero, errors are always logged
+		Exclude []string  `yaml:"exclude,omitempty"` // paths not logged, a trailing * match a prefix
+		Output  string    `yaml:"output,omitempty"`  // file path, stdout when empty, rotated as the logs
+		Writer  io.Writer `yaml:"-"`
+	}
+
+	// Record the status and the bytes of a response, whoever writes it
+	accessWriter struct {
+		http.ResponseWriter
+		code  int
+		bytes int64
+	}
+)
+
+// Access log formats
+const (
+	CommonLog   = `%h %l %u %t "%r" %>s %b`
+	CombinedLog = `%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-Agent}i"`
+)
+
+// WriteHeader record the first status code
+func (a *accessWriter) WriteHeader(code int) {
+	if a.code == 0 {
+		a.code = code
+	}
+	a.ResponseWriter.WriteHeader(code)
+}
+
+// Write record the bytes sent
+func (a *accessWriter) Write(b []byte) (int, error) {
+	if a.code == 0 {
+		a.code = http.StatusOK
+	}
+	n, err := a.ResponseWriter.Write(b)
+	a.bytes += int64(n)
+	return n, err
+}
+
+// Flush the underlying writer if supported
+func (a *accessWriter) Flush() {
+	if f, ok := a.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack the connection, used by websockets
+func (a *accessWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := a.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer doesn't support hijacking")
+	}
+	a.code = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+// Unwrap return the original writer, used by http.ResponseController
+func (a *accessWriter) Unwrap() http.ResponseWriter {
+	return a.ResponseWriter
+}
+
+// Status return the recorded status, 200 when nothing was written
+func (a *accessWriter) status() int {
+	if a.code == 0 {
+		return http.StatusOK
+	}
+	return a.code
+}
+
+// Writer return the access log sink, opened once, the lock must be held
+func (l *AccessLog) writer(c *Config) io.Writer {
+	l.once.Do(func() {
+		l.out = l.Writer
+		if l.out != nil {
+			return
+		}
+		l.out = os.Stdout
+		if l.Output != "" {
+			// rotated with the logs settings
+			f, err := newRotateWriter(l.Output, c.Logs)
+			if err != nil {
+				c.logger().Error("access log file", "path", l.Output, "error", err)
+				return
+			}
+			l.out, l.file = f, f
+		}
+	})
+	return l.out
+}
+
+// Close the access log file, if opened
+func (l *AccessLog) close() error {
+	l.mu.Lock()
+	f := l.file
+	l.mu.Unlock()
+	if f == nil {
+		return nil
+	}
+	return f.Close()
+}
+
+// Skip check the path exclusions and the sampling, server errors are never skipped
+func (l *AccessLog) skip(path string, status int) bool {
+	for _, e := range l.Exclude {
+		if e == path || (strings.HasSuffix(e, "*") && strings.HasPrefix(path, strings.TrimSuffix(e, "*"))) {
+			return true
+		}
+	}
+	return status < http.StatusInternalServerError && l.Sample > 0 && l.Sample < 1 && rand.Float64() >= l.Sample
+}
+
+// Log a served request
+func (l *AccessLog) log(c *context, w *accessWriter, start time.Time) {
+	r := c.request.r
+	if l.skip(r.URL.Path, w.status()) {
+		return
+	}
+	var line string
+	switch strings.ToLower(l.Format) {
+	case "json":
+		entry := map[string]interface{}{
+			"time":       start.Format(time.RFC3339),
+			"ip":         c.request.RealIP(),
+			"method":     r.Method,
+			"uri":        r.URL.RequestURI(),
+			"proto":      r.Proto,
+			"status":     w.status(),
+			"bytes":      w.bytes,
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"referer":    r.Referer(),
+			"user_agent": r.UserAgent(),
+		}
+		if user := principal(c); user != "" {
+			entry["user"] = user
+		}
+		if id, ok := c.Get(ContextRequestID).(string); ok {
+			entry["request_id"] = id
+		}
+		b, _ := json.Marshal(entry)
+		line = string(b)
+	case "", "common":
+		line = l.format(CommonLog, c, w, start)
+	case "combined":
+		line = l.format(CombinedLog, c, w, start)
+	default:
+		line = l.format(l.Format, c, w, start)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	io.WriteString(l.writer(c.config), line+"\n")
+}
+
+// Format a request with Apache directives: %h %l %u %t %r %s %>s %b %B %D %T %m %U %q %H and %{Header}i %{Header}o
+func (l *AccessLog) format(tmpl string, c *context, w *accessWriter, start time.Time) string {
+	r := c.request.r
+	var b strings.Builder
+	for i := 0; i < len(tmpl); i++ {
+		if tmpl[i] != '%' || i == len(tmpl)-1 {
+			b.WriteByte(tmpl[i])
+			continue
+		}
+		i++
+		if tmpl[i] == '>' && i < len(tmpl)-1 {
+			i++
+		}
+		if tmpl[i] == '{' {
+			end := strings.IndexByte(tmpl[i:], '}')
+			if end < 0 || i+end == len(tmpl)-1 {
+				b.WriteString(tmpl[i-1:])
+				break
+			}
+			name, kind := tmpl[i+1:i+end], tmpl[i+end+1]
+			i += end + 1
+			switch kind {
+			case 'i':
+				b.WriteString(dash(r.Header.Get(name)))
+			case 'o':
+				b.WriteString(dash(w.Header().Get(name)))
+			default:
+				b.WriteString("%{" + name + "}" + string(kind))
+			}
+			continue
+		}
+		switch tmpl[i] {
+		case 'h':
+			b.WriteString(c.request.RealIP())
+		case 'l':
+			b.WriteString("-")
+		case 'u':
+			b.WriteString(dash(principal(c)))
+		case 't':
+			b.WriteString(start.Format("[02/Jan/2006:15:04:05 -0700]"))
+		case 'r':
+			b.WriteString(r.Method + " " + r.URL.RequestURI() + " " + r.Proto)
+		case 's':
+			b.WriteString(strconv.Itoa(w.status()))
+		case 'b':
+			if w.bytes == 0 {
+				b.WriteString("-")
+			} else {
+				b.WriteString(strconv.FormatInt(w.bytes, 10))
+			}
+		case 'B':
+			b.WriteString(strconv.FormatInt(w.bytes, 10))
+		case 'D':
+			b.WriteString(strconv.FormatInt(time.Since(start).Microseconds(), 10))
+		case 'T':
+			b.WriteString(strconv.FormatInt(int64(time.Since(start).Seconds()), 10))
+		case 'm':
+			b.WriteString(r.Method)
+		case 'U':
+			b.WriteString(r.URL.Path)
+		case 'q':
+			if r.URL.RawQuery != "" {
+				b.WriteString("?" + r.URL.RawQuery)
+			}
+		case 'H':
+			b.WriteString(r.Proto)
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteString(tmpl[i-1 : i+1])
+		}
+	}
+	return b.String()
+}
+
+// Principal return the identifier of the authenticated user, the sub claim for a JWT, empty when unknown
+func principal(c Context) string {
+	switch p := c.Get(ContextPrincipal).(type) {
+	case string:
+		return p
+	case Claims:
+		sub, _ := p["sub"].(string)
+		return sub
+	case Principal:
+		return p.ID()
+	case fmt.Stringer:
+		return p.String()
+	}
+	return ""
+}
+
+// Dash replace an empty value as in Apache logs
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// Access log a request with the config access log, or at info level when it isn't set
+func (r *router) access(c *context, w *accessWriter, start time.Time) {
+	if r.config.AccessLog != nil {
+		r.config.AccessLog.log(c, w, start)
+		return
+	}
+	c.Logger().Info("request", "ip", c.request.RealIP(), "method", c.request.r.Method, "uri", c.request.r.URL.RequestURI(), "status", w.status(), "bytes", w.bytes, "latency", time.Since(start))
+}
@@ -0,0 +1,65 @@
+package fresh
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	file, err := ioutil.TempFile("", "fresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("hello")
+	file.Close()
+	buf := new(bytes.Buffer)
+	f := setup()
+	f.config.AccessLog = &AccessLog{Format: "combined", Exclude: []string{"/healthz"}, Writer: buf}
+	f.GET("/file", func(c Context) error {
+		return c.Response().File(http.StatusOK, file.Name())
+	})
+	f.GET("/healthz", func(c Context) error {
+		return c.Response().Code(http.StatusOK)
+	})
+	for _, path := range []string{"/file?x=1", "/healthz"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("User-Agent", "test")
+		f.router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	expected := regexp.MustCompile(`^10\.0\.0\.1 - - \[.+\] "GET /file\?x=1 HTTP/1\.1" 200 5 "-" "test"\n$`)
+	if !expected.MatchString(buf.String()) {
+		t.Fatal("Unexpected access log", buf.String())
+	}
+}
+
+func TestAccessLog_Formats(t *testing.T) {
+	buf := new(bytes.Buffer)
+	f := setup()
+	f.config.AccessLog = &AccessLog{Format: "%m %U %>s %B %{X-Out}o", Writer: buf}
+	f.GET("/custom", func(c Context) error {
+		c.Response().Get().Header().Set("X-Out", "yes")
+		return c.Response().Raw(http.StatusAccepted, "abc")
+	})
+	req, _ := http.NewRequest("GET", "/custom", nil)
+	f.router.ServeHTTP(httptest.NewRecorder(), req)
+	if buf.String() != "GET /custom 202 3 yes\n" {
+		t.Fatal("Unexpected custom access log", buf.String())
+	}
+	buf.Reset()
+	f.config.AccessLog = &AccessLog{Format: "json", Writer: buf}
+	req, _ = http.NewRequest("GET", "/missing", nil)
+	f.router.ServeHTTP(httptest.NewRecorder(), req)
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil || entry["status"] != float64(http.StatusNotFound) || !strings.HasPrefix(entry["uri"].(string), "/missing") {
+		t.Fatal("Unexpected json access log", buf.String(), err)
+	}
+}
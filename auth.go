@@ -0,0 +1,237 @@
+package fresh
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type (
+	// JWT verification options, keys are []byte for HS256, *rsa.PublicKey for RS256 and *ecdsa.PublicKey for ES256
+	JWT struct {
+		Key      interface{}
+		Keys     map[string]interface{}
+		Realm    string
+		Issuer   string
+		Audience string
+		Leeway   time.Duration
+		Lookup   func(Claims) (interface{}, error)
+	}
+
+	// APIKey options, the key is read from a header or a query parameter
+	APIKey struct {
+		Header string
+		Query  string
+		Lookup func(string) (interface{}, bool)
+	}
+
+	// Claims of a verified JWT
+	Claims map[string]interface{}
+)
+
+var (
+	// ErrUnauthorized returned when credentials are missing or wrong
+	ErrUnauthorized = errors.New(http.StatusText(http.StatusUnauthorized))
+	// ErrInvalidToken returned when a JWT is malformed or its signature doesn't match
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenExpired returned when a JWT is expired or not yet valid
+	ErrTokenExpired = errors.New("token expired or not yet valid")
+	// ErrNoLookup returned when an api key middleware has no lookup func
+	ErrNoLookup = errors.New("api key lookup not set")
+)
+
+// Challenge quote escaper of the WWW-Authenticate parameters
+var challenge = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// BasicAuth middleware, lookup return the expected password of a user
+func BasicAuth(realm string, lookup func(string) (string, bool)) HandlerFunc {
+	return func(c Context) error {
+		user, password, ok := c.Request().Get().BasicAuth()
+		if ok {
+			expected, found := lookup(user)
+			// compare hashes to not leak the password length
+			sent, stored := sha256.Sum256([]byte(password)), sha256.Sum256([]byte(expected))
+			if found && subtle.ConstantTimeCompare(sent[:], stored[:]) == 1 {
+				c.Set(ContextPrincipal, user)
+				return nil
+			}
+		}
+		c.Response().Get().Header().Set(WWWAuthenticate, `Basic realm="`+challenge.Replace(realm)+`", charset="UTF-8"`)
+		return c.Response().Error(http.StatusUnauthorized, ErrUnauthorized)
+	}
+}
+
+// JWTAuth middleware, verify a bearer token and store its claims in the context
+func JWTAuth(j JWT) HandlerFunc {
+	return func(c Context) error {
+		claims, err := j.Verify(c.Request().AuthBearer())
+		var principal interface{} = claims
+		if err == nil && j.Lookup != nil {
+			principal, err = j.Lookup(claims)
+		}
+		if err != nil {
+			header := `Bearer realm="` + challenge.Replace(j.Realm) + `"`
+			if err != ErrUnauthorized {
+				header += `, error="invalid_token"`
+			}
+			c.Response().Get().Header().Set(WWWAuthenticate, header)
+			return c.Response().Error(http.StatusUnauthorized, err)
+		}
+		c.Set(ContextClaims, claims)
+		c.Set(ContextPrincipal, principal)
+		return nil
+	}
+}
+
+// APIKeyAuth middleware, lookup return the principal that owns a key
+func APIKeyAuth(k APIKey) HandlerFunc {
+	if k.Header == "" && k.Query == "" {
+		k.Header = "X-API-Key"
+	}
+	return func(c Context) error {
+		if k.Lookup == nil {
+			return c.Response().Error(http.StatusInternalServerError, ErrNoLookup)
+		}
+		key := ""
+		if k.Header != "" {
+			key = c.Request().Header().Get(k.Header)
+		}
+		if key == "" && k.Query != "" {
+			key = c.Request().QueryParam(k.Query)
+		}
+		if key != "" {
+			if principal, ok := k.Lookup(key); ok {
+				c.Set(ContextPrincipal, principal)
+				return nil
+			}
+		}
+		return c.Response().Error(http.StatusUnauthorized, ErrUnauthorized)
+	}
+}
+
+// Verify a JWT signature and its registered claims
+func (j *JWT) Verify(token string) (Claims, error) {
+	if token == "" {
+		return nil, ErrUnauthorized
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	key := j.Key
+	if k, ok := j.Keys[header.Kid]; ok {
+		key = k
+	}
+	if !verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature) {
+		return nil, ErrInvalidToken
+	}
+	claims := Claims{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	return claims, j.validate(claims)
+}
+
+// Validate exp, nbf, iss and aud claims
+func (j *JWT) validate(claims Claims) error {
+	now := time.Now()
+	if exp, ok := claims.time("exp"); ok && now.After(exp.Add(j.Leeway)) {
+		return ErrTokenExpired
+	}
+	if nbf, ok := claims.time("nbf"); ok && now.Add(j.Leeway).Before(nbf) {
+		return ErrTokenExpired
+	}
+	if j.Issuer != "" && claims["iss"] != j.Issuer {
+		return ErrInvalidToken
+	}
+	if j.Audience != "" {
+		for _, aud := range claims.Strings("aud") {
+			if aud == j.Audience {
+				return nil
+			}
+		}
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// Strings return a claim as a list of strings, a single string is allowed
+func (c Claims) Strings(k string) []string {
+	switch v := c[k].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// Time return a numeric date claim
+func (c Claims) time(k string) (time.Time, bool) {
+	if v, ok := c[k].(float64); ok {
+		return time.Unix(int64(v), 0), true
+	}
+	return time.Time{}, false
+}
+
+// Decode a base64 url JWT segment
+func decodeSegment(s string, i interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, i)
+}
+
+// Verify a signature, the key type must match the algorithm
+func verifySignature(alg string, key interface{}, input string, signature []byte) bool {
+	hash := sha256.Sum256([]byte(input))
+	switch alg {
+	case "HS256":
+		secret, ok := key.([]byte)
+		if !ok || len(secret) == 0 {
+			return false
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(input))
+		return hmac.Equal(mac.Sum(nil), signature)
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(pub, crypto.SHA256, hash[:], signature) == nil
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(pub, hash[:], r, s)
+	}
+	return false
+}
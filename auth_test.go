@@ -0,0 +1,123 @@
+package fresh
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func token(alg string, key interface{}, claims Claims) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		hash := sha256.Sum256([]byte(input))
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, hash[:])
+	case *ecdsa.PrivateKey:
+		hash := sha256.Sum256([]byte(input))
+		r, s, _ := ecdsa.Sign(rand.Reader, k, hash[:])
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func authorize(f fresh, path string, set func(*http.Request)) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	set(req)
+	f.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuth_Basic(t *testing.T) {
+	f := setup()
+	f.GET("/basic", func(c Context) error {
+		return c.Response().Raw(http.StatusOK, c.Get(ContextPrincipal).(string))
+	}).Before(BasicAuth(`fresh "app"`, func(user string) (string, bool) {
+		return "secret", user == "admin"
+	}))
+	rec := authorize(f, "/basic", func(r *http.Request) { r.SetBasicAuth("admin", "secret") })
+	if rec.Code != http.StatusOK || rec.Body.String() != "admin" {
+		t.Fatal("Expected valid credentials, returned", rec.Code)
+	}
+	rec = authorize(f, "/basic", func(r *http.Request) { r.SetBasicAuth("admin", "wrong") })
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get(WWWAuthenticate) != `Basic realm="fresh \"app\"", charset="UTF-8"` {
+		t.Fatal("Expected an escaped challenge, returned", rec.Code, rec.Header().Get(WWWAuthenticate))
+	}
+}
+
+func TestAuth_JWT(t *testing.T) {
+	f := setup()
+	secret := []byte("secret")
+	private, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	f.GET("/jwt", func(c Context) error {
+		return c.Response().Raw(http.StatusOK, c.Get(ContextClaims).(Claims)["sub"].(string))
+	}).Before(JWTAuth(JWT{Key: secret, Keys: map[string]interface{}{"": secret}, Issuer: "fresh", Audience: "api"}))
+	f.GET("/es256", func(c Context) error {
+		return c.Response().Code(http.StatusOK)
+	}).Before(JWTAuth(JWT{Key: &private.PublicKey}))
+	f.GET("/rs256", func(c Context) error {
+		return c.Response().Code(http.StatusOK)
+	}).Before(JWTAuth(JWT{Key: &rsaKey.PublicKey, Audience: "api"}))
+	valid := Claims{"sub": "1", "iss": "fresh", "aud": []string{"web", "api"}, "exp": time.Now().Add(time.Minute).Unix()}
+	tests := []struct {
+		path  string
+		token string
+		code  int
+	}{
+		{"/jwt", token("HS256", secret, valid), http.StatusOK},
+		{"/jwt", token("HS256", []byte("other"), valid), http.StatusUnauthorized},
+		{"/jwt", token("HS256", secret, Claims{"iss": "fresh", "aud": "api", "exp": time.Now().Add(-time.Minute).Unix()}), http.StatusUnauthorized},
+		{"/jwt", token("HS256", secret, Claims{"iss": "fresh", "aud": "web"}), http.StatusUnauthorized},
+		{"/jwt", "", http.StatusUnauthorized},
+		{"/es256", token("ES256", private, Claims{}), http.StatusOK},
+		{"/es256", token("HS256", secret, Claims{}), http.StatusUnauthorized},
+		{"/rs256", token("RS256", rsaKey, Claims{"aud": "api"}), http.StatusOK},
+		{"/rs256", token("RS256", rsaKey, Claims{"aud": "web api"}), http.StatusUnauthorized},
+		{"/rs256", token("ES256", private, Claims{"aud": "api"}), http.StatusUnauthorized},
+	}
+	for i, test := range tests {
+		rec := authorize(f, test.path, func(r *http.Request) { r.Header.Set(Authorization, "Bearer "+test.token) })
+		if rec.Code != test.code {
+			t.Fatal("Token", i, "returned", rec.Code, "instead of", test.code)
+		}
+	}
+}
+
+func TestAuth_APIKey(t *testing.T) {
+	f := setup()
+	f.GET("/key", func(c Context) error {
+		return c.Response().Code(http.StatusOK)
+	}).Before(APIKeyAuth(APIKey{Header: "X-API-Key", Query: "key", Lookup: func(key string) (interface{}, bool) {
+		return "service", key == "k1"
+	}}))
+	if rec := authorize(f, "/key?key=k1", func(r *http.Request) {}); rec.Code != http.StatusOK {
+		t.Fatal("Expected a valid query key, returned", rec.Code)
+	}
+	if rec := authorize(f, "/key", func(r *http.Request) { r.Header.Set("X-API-Key", "k2") }); rec.Code != http.StatusUnauthorized {
+		t.Fatal("Expected an invalid header key, returned", rec.Code)
+	}
+	f.GET("/nolookup", func(c Context) error {
+		return c.Response().Code(http.StatusOK)
+	}).Before(APIKeyAuth(APIKey{}))
+	if rec := authorize(f, "/nolookup", func(r *http.Request) { r.Header.Set("X-API-Key", "k1") }); rec.Code != http.StatusInternalServerError {
+		t.Fatal("Expected a missing lookup error, returned", rec.Code)
+	}
+}
@@ -0,0 +1,82 @@
+package fresh
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+type (
+	// Authorizer check if the principal stored in the context has the required roles or permissions
+	Authorizer interface {
+		Authorize(principal interface{}, required []string) bool
+	}
+
+	// AuthorizerFunc adapt a func to the Authorizer interface
+	AuthorizerFunc func(interface{}, []string) bool
+
+	// Principal exposes the identifier, the roles and the permissions of an authenticated user
+	Principal interface {
+		ID() string
+		Permissions() []string
+	}
+
+	// Default authorizer
+	authorizer struct{}
+)
+
+// ErrForbidden returned when the principal doesn't have the required permissions
+var ErrForbidden = errors.New(http.StatusText(http.StatusForbidden))
+
+// Authorize call the func
+func (f AuthorizerFunc) Authorize(principal interface{}, required []string) bool {
+	return f(principal, required)
+}
+
+// Authorize a Principal, a list of strings or JWT claims with roles, permissions or scope
+func (authorizer) Authorize(principal interface{}, required []string) bool {
+	var granted []string
+	switch p := principal.(type) {
+	case Principal:
+		granted = p.Permissions()
+	case []string:
+		granted = p
+	case Claims:
+		for _, k := range []string{"roles", "permissions"} {
+			granted = append(granted, p.Strings(k)...)
+		}
+		// oauth scopes are a space separated string
+		for _, scope := range p.Strings("scope") {
+			granted = append(granted, strings.Fields(scope)...)
+		}
+	}
+	for _, r := range required {
+		found := false
+		for _, g := range granted {
+			found = found || g == r
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Authorize check the route requirements against the context principal
+func (r *router) authorize(h *handler, c *context) error {
+	if len(h.require) == 0 {
+		return nil
+	}
+	principal := c.Get(ContextPrincipal)
+	if principal == nil {
+		return c.Response().Error(http.StatusUnauthorized, ErrUnauthorized)
+	}
+	var a Authorizer = authorizer{}
+	if r.config.Authorizer != nil {
+		a = r.config.Authorizer
+	}
+	if !a.Authorize(principal, h.require) {
+		return c.Response().Error(http.StatusForbidden, ErrForbidden)
+	}
+	return nil
+}
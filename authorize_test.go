@@ -0,0 +1,48 @@
+package fresh
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthorize_Require(t *testing.T) {
+	f := setup()
+	roles := map[string][]string{"k1": {"admin", "billing:write"}, "k2": {"billing:read"}}
+	admin := f.Group("/admin").Before(APIKeyAuth(APIKey{Lookup: func(key string) (interface{}, bool) {
+		r, ok := roles[key]
+		return r, ok
+	}})).Require("admin")
+	admin.POST("/invoices", func(c Context) error {
+		return c.Response().Code(http.StatusCreated)
+	}).Require("billing:write")
+	tests := map[string]int{"k1": http.StatusCreated, "k2": http.StatusForbidden, "k3": http.StatusUnauthorized}
+	for key, code := range tests {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/admin/invoices", nil)
+		req.Header.Set("X-API-Key", key)
+		f.router.ServeHTTP(rec, req)
+		if rec.Code != code {
+			t.Fatal("Key", key, "returned", rec.Code, "instead of", code)
+		}
+	}
+}
+
+func TestAuthorize_Claims(t *testing.T) {
+	tests := []struct {
+		claims   Claims
+		required []string
+		allowed  bool
+	}{
+		{Claims{"scope": "read write"}, []string{"read"}, true},
+		{Claims{"scope": "read write"}, []string{"read", "write"}, true},
+		{Claims{"scope": "read"}, []string{"write"}, false},
+		{Claims{"roles": []interface{}{"admin"}}, []string{"admin"}, true},
+		{Claims{"permissions": "billing:read billing:write"}, []string{"billing:read"}, false},
+	}
+	for i, test := range tests {
+		if (authorizer{}).Authorize(test.claims, test.required) != test.allowed {
+			t.Fatal("Claims", i, "expected allowed", test.allowed)
+		}
+	}
+}
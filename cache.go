@@ -0,0 +1,300 @@
+package fresh
+
+import (
+	"container/list"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Cache config, the store is an in-memory LRU when not set
+	Cache struct {
+		once  sync.Once
+		TTL   time.Duration `yaml:"ttl,omitempty"`
+		Size  int           `yaml:"size,omitempty"`
+		Vary  []string      `yaml:"vary,omitempty"`
+		Store CacheStore    `yaml:"-"`
+	}
+
+	// CacheEntry is a buffered response saved in a store
+	CacheEntry struct {
+		Code    int
+		Body    []byte
+		Tags    []string
+		Header  http.Header
+		Vary    map[string]string
+		Shared  bool // public response, replayed to requests with credentials
+		Created time.Time
+	}
+
+	// CacheStore save and retrieve cached responses
+	CacheStore interface {
+		Get(string) (*CacheEntry, bool)
+		Set(string, *CacheEntry, time.Duration)
+		Invalidate(...string)
+	}
+
+	// Route cache settings
+	cacheRule struct {
+		ttl  time.Duration
+		tags []string
+	}
+
+	// In-memory LRU store
+	memoryStore struct {
+		sync.Mutex
+		size  int
+		items map[string]*list.Element
+		tags  map[string]map[string]struct{}
+		list  *list.List
+	}
+
+	memoryItem struct {
+		key     string
+		entry   *CacheEntry
+		expires time.Time
+	}
+)
+
+const (
+	cacheTTL  = time.Minute
+	cacheSize = 1000
+)
+
+// Representation headers saved with a cached response, per request headers are never replayed
+var cacheHeaders = []string{ContentType, ContentEncoding, ETag, LastModified, CacheControl, Vary}
+
+// NewMemoryStore return an in-memory LRU cache store with a max number of entries
+func NewMemoryStore(size int) CacheStore {
+	if size <= 0 {
+		size = cacheSize
+	}
+	return &memoryStore{
+		size:  size,
+		items: make(map[string]*list.Element),
+		tags:  make(map[string]map[string]struct{}),
+		list:  list.New(),
+	}
+}
+
+// Get a cached entry, expired entries are removed
+func (m *memoryStore) Get(key string) (*CacheEntry, bool) {
+	m.Lock()
+	defer m.Unlock()
+	elm, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := elm.Value.(*memoryItem)
+	if time.Now().After(item.expires) {
+		m.remove(elm)
+		return nil, false
+	}
+	m.list.MoveToFront(elm)
+	return item.entry, true
+}
+
+// Set a cached entry, the least recently used is evicted when the store is full
+func (m *memoryStore) Set(key string, entry *CacheEntry, ttl time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	if elm, ok := m.items[key]; ok {
+		m.remove(elm)
+	}
+	m.items[key] = m.list.PushFront(&memoryItem{key, entry, time.Now().Add(ttl)})
+	for _, tag := range entry.Tags {
+		if m.tags[tag] == nil {
+			m.tags[tag] = make(map[string]struct{})
+		}
+		m.tags[tag][key] = struct{}{}
+	}
+	for m.list.Len() > m.size {
+		m.remove(m.list.Back())
+	}
+}
+
+// Invalidate remove all the entries with the given tags
+func (m *memoryStore) Invalidate(tags ...string) {
+	m.Lock()
+	defer m.Unlock()
+	for _, tag := range tags {
+		for key := range m.tags[tag] {
+			if elm, ok := m.items[key]; ok {
+				m.remove(elm)
+			}
+		}
+		delete(m.tags, tag)
+	}
+}
+
+// Remove an element and its tags references
+func (m *memoryStore) remove(elm *list.Element) {
+	item := m.list.Remove(elm).(*memoryItem)
+	delete(m.items, item.key)
+	for _, tag := range item.entry.Tags {
+		delete(m.tags[tag], item.key)
+		if len(m.tags[tag]) == 0 {
+			delete(m.tags, tag)
+		}
+	}
+}
+
+// Return the cache store, create the default one if missing
+func (c *Cache) store() CacheStore {
+	c.once.Do(func() {
+		if c.Store == nil {
+			c.Store = NewMemoryStore(c.Size)
+		}
+	})
+	return c.Store
+}
+
+// Key of a request, built with method, host, path, query, the vary headers and the authenticated user
+func (c *Cache) key(r *http.Request, user string) string {
+	key := "GET " + strings.ToLower(r.Host) + r.URL.Path + "?" + r.URL.Query().Encode()
+	for _, h := range c.Vary {
+		key += "\n" + strings.ToLower(h) + ":" + r.Header.Get(h)
+	}
+	if user != "" {
+		key += "\nprincipal:" + user
+	}
+	return key
+}
+
+// Shared check if a Cache-Control header allow a shared cache to store a response with credentials
+func shared(header string) bool {
+	for _, d := range strings.Split(header, ",") {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d == "public" || strings.HasPrefix(d, "s-maxage=") {
+			return true
+		}
+	}
+	return false
+}
+
+// Cacheable check request method and cache control directives
+func cacheable(r *http.Request, directive string) bool {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+	return !cacheControl(r.Header.Get(CacheControl), directive)
+}
+
+// CacheControl check if a Cache-Control header contains a directive
+func cacheControl(header, directive string) bool {
+	for _, d := range strings.Split(header, ",") {
+		if strings.EqualFold(strings.TrimSpace(d), directive) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxAge return the s-maxage or max-age value of a Cache-Control header
+func maxAge(header string) time.Duration {
+	for _, name := range []string{"s-maxage=", "max-age="} {
+		for _, d := range strings.Split(header, ",") {
+			d = strings.ToLower(strings.TrimSpace(d))
+			if strings.HasPrefix(d, name) {
+				if s, err := strconv.Atoi(strings.TrimPrefix(d, name)); err == nil {
+					return time.Duration(s) * time.Second
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// Replay a cached response, return true if the controller can be skipped
+func (r *response) replay(rule *cacheRule) bool {
+	if rule == nil || r.config.Cache == nil || !cacheable(r.r, "no-cache") || !cacheable(r.r, "no-store") {
+		return false
+	}
+	// pages with a csp nonce need a fresh body
+	if r.context.Get(ContextNonce) != nil {
+		return false
+	}
+	user := principal(r.context)
+	entry, ok := r.config.Cache.store().Get(r.config.Cache.key(r.r, user))
+	if !ok {
+		return false
+	}
+	// credentials without a known user get only the public responses
+	if user == "" && r.r.Header.Get(Authorization) != "" && !entry.Shared {
+		return false
+	}
+	for h, v := range entry.Vary {
+		if r.r.Header.Get(h) != v {
+			return false
+		}
+	}
+	for _, k := range cacheHeaders {
+		if v, ok := entry.Header[k]; ok {
+			r.w.Header()[k] = append([]string(nil), v...)
+		}
+	}
+	r.w.Header().Set(Age, strconv.Itoa(int(time.Since(entry.Created).Seconds())))
+	r.set(entry.Code, entry.Body)
+	return true
+}
+
+// Save a buffered response in the cache store
+func (r *response) store(rule *cacheRule) {
+	if rule == nil || r.config.Cache == nil {
+		return
+	}
+	vary(r.w.Header(), r.config.Cache.Vary...)
+	if !cacheable(r.r, "no-store") || r.reply.code != http.StatusOK || r.reply.stream != nil {
+		return
+	}
+	h := r.w.Header()
+	cc := h.Get(CacheControl)
+	if cacheControl(cc, "no-store") || cacheControl(cc, "no-cache") || cacheControl(cc, "private") || h.Get(SetCookie) != "" {
+		return
+	}
+	if r.context.Get(ContextNonce) != nil {
+		return
+	}
+	user := principal(r.context)
+	if user == "" && r.r.Header.Get(Authorization) != "" && !shared(cc) {
+		return
+	}
+	ttl := maxAge(cc)
+	if ttl == 0 {
+		ttl = rule.ttl
+	}
+	if ttl == 0 {
+		ttl = r.config.Cache.TTL
+	}
+	if ttl == 0 {
+		ttl = cacheTTL
+	}
+	entry := &CacheEntry{
+		Code:    r.reply.code,
+		Body:    r.reply.response,
+		Tags:    rule.tags,
+		Header:  make(http.Header),
+		Vary:    make(map[string]string),
+		Shared:  shared(cc),
+		Created: time.Now(),
+	}
+	for _, k := range cacheHeaders {
+		if v, ok := h[k]; ok {
+			entry.Header[k] = append([]string(nil), v...)
+		}
+	}
+	// headers the response vary on, beside the ones in the key
+	for _, v := range h[Vary] {
+		for _, k := range strings.Split(v, ",") {
+			if k = strings.TrimSpace(k); k == "*" {
+				return
+			} else if k != "" {
+				entry.Vary[k] = r.r.Header.Get(k)
+			}
+		}
+	}
+	r.config.Cache.store().Set(r.config.Cache.key(r.r, user), entry, ttl)
+}
@@ -0,0 +1,127 @@
+package fresh
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCache_Replay(t *testing.T) {
+	f := setup()
+	calls := 0
+	f.GET("/cached", func(c Context) error {
+		calls++
+		return c.Response().Raw(http.StatusOK, strconv.Itoa(calls))
+	}).Cache(time.Minute, "list")
+	f.POST("/cached", func(c Context) error {
+		c.Invalidate("list")
+		return c.Response().Code(http.StatusCreated)
+	})
+	get := func(header ...string) string {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/cached?b=2&a=1", nil)
+		if len(header) > 0 {
+			req.Header.Set(CacheControl, header[0])
+		}
+		f.router.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+	if get() != "1" || get() != "1" {
+		t.Fatal("Expected a cached response")
+	}
+	if get("no-cache") != "2" || get() != "2" {
+		t.Fatal("Expected Cache-Control no-cache to bypass the cache")
+	}
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/cached", nil)
+	f.router.ServeHTTP(rec, req)
+	if get() != "3" {
+		t.Fatal("Expected the cache to be invalidated by tag")
+	}
+}
+
+func TestCache_MemoryStore(t *testing.T) {
+	store := NewMemoryStore(2)
+	store.Set("a", &CacheEntry{Tags: []string{"x"}}, time.Minute)
+	store.Set("b", &CacheEntry{}, time.Minute)
+	store.Get("a")
+	store.Set("c", &CacheEntry{}, time.Minute)
+	if _, ok := store.Get("b"); ok {
+		t.Fatal("Expected the least recently used entry to be evicted")
+	}
+	store.Set("d", &CacheEntry{}, -time.Second)
+	if _, ok := store.Get("d"); ok {
+		t.Fatal("Expected an expired entry")
+	}
+	store.Invalidate("x")
+	if _, ok := store.Get("a"); ok {
+		t.Fatal("Expected an invalidated entry")
+	}
+}
+
+func TestCache_Headers(t *testing.T) {
+	f := setup()
+	f.config.RequestID = &RequestID{}
+	calls := 0
+	f.GET("/cached", func(c Context) error {
+		calls++
+		c.Response().Get().Header().Set("X-Custom", strconv.Itoa(calls))
+		return c.Response().Raw(http.StatusOK, c.Request().Get().Host)
+	}).Cache(time.Minute)
+	get := func(host string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://"+host+"/cached", nil)
+		f.router.ServeHTTP(rec, req)
+		return rec
+	}
+	first, second := get("a.com"), get("a.com")
+	if second.Body.String() != "a.com" || second.Header().Get(ETag) != first.Header().Get(ETag) || second.Header().Get("X-Custom") != "" {
+		t.Fatal("Expected only the representation headers to be replayed", second.Header())
+	}
+	if id := second.Header().Get(XRequestID); id == "" || id == first.Header().Get(XRequestID) {
+		t.Fatal("Expected a new request id on a replayed response", id)
+	}
+	if get("b.com").Body.String() != "b.com" || calls != 2 {
+		t.Fatal("Expected a cache entry per host")
+	}
+}
+
+func TestCache_Private(t *testing.T) {
+	f := setup()
+	calls := 0
+	f.GET("/me", func(c Context) error {
+		calls++
+		return c.Response().Raw(http.StatusOK, c.Get(ContextPrincipal).(string))
+	}).Before(BasicAuth("fresh", func(user string) (string, bool) {
+		return "secret", true
+	})).Cache(time.Minute)
+	f.GET("/token", func(c Context) error {
+		calls++
+		return c.Response().Raw(http.StatusOK, strconv.Itoa(calls))
+	}).Cache(time.Minute)
+	get := func(path string, set func(*http.Request)) string {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		set(req)
+		f.router.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+	alice := func(r *http.Request) { r.SetBasicAuth("alice", "secret") }
+	bob := func(r *http.Request) { r.SetBasicAuth("bob", "secret") }
+	if get("/me", alice) != "alice" || get("/me", bob) != "bob" || get("/me", alice) != "alice" || calls != 2 {
+		t.Fatal("Expected a cache entry per user", calls)
+	}
+	// credentials without a principal aren't cached
+	bearer := func(r *http.Request) { r.Header.Set(Authorization, "Bearer abc") }
+	if get("/token", bearer) != "3" || get("/token", bearer) != "4" {
+		t.Fatal("Expected the responses to requests with credentials not to be cached")
+	}
+	// a csp nonce is never replayed
+	f.config.Security = &Security{CSP: &CSP{Nonce: true}}
+	none := func(r *http.Request) {}
+	if get("/token", none) != "5" || get("/token", none) != "6" {
+		t.Fatal("Expected the responses with a nonce not to be cached")
+	}
+}
@@ -0,0 +1,246 @@
+package fresh
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v2"
+)
+
+type (
+	// Encoder marshal a value in a given media type
+	Encoder func(interface{}) ([]byte, error)
+
+	// Decoder unmarshal a request body in a given value
+	Decoder func(io.Reader, interface{}) error
+
+	// Registered encoder
+	encoder struct {
+		mime    string
+		content string
+		encode  Encoder
+	}
+
+	// Registered decoder
+	decoder struct {
+		mime   string
+		decode Decoder
+	}
+
+	// Accept header entry
+	accept struct {
+		mime    string
+		quality float64
+		params  int
+	}
+)
+
+var (
+	// ErrNotAcceptable returned when no encoder match the request Accept header
+	ErrNotAcceptable = errors.New(http.StatusText(http.StatusNotAcceptable))
+	// ErrUnsupportedMediaType returned when no decoder match the request Content-Type header
+	ErrUnsupportedMediaType = errors.New(http.StatusText(http.StatusUnsupportedMediaType))
+	// ErrNotProto returned when a protocol buffers codec receive a value that isn't a proto.Message
+	ErrNotProto = errors.New("value is not a proto.Message")
+)
+
+// Default encoders, the first one is used when the client accept everything
+func encoders() []encoder {
+	text := func(i interface{}) ([]byte, error) {
+		return []byte(fmt.Sprint(i)), nil
+	}
+	// escaped, negotiated values are data and never markup
+	htmlText := func(i interface{}) ([]byte, error) {
+		return []byte(html.EscapeString(fmt.Sprint(i))), nil
+	}
+	return []encoder{
+		{"application/json", MIMEAppJSON, json.Marshal},
+		{"application/xml", MIMEAppXML, xml.Marshal},
+		{"text/xml", MIMETextXML, xml.Marshal},
+		{"text/plain", MIMEText, text},
+		{"text/html", MIMETextHTML, htmlText},
+		{"application/x-yaml", MIMEAppYAML, yaml.Marshal},
+		{"application/msgpack", MIMEAppMsgPack, msgpack.Marshal},
+		{"application/x-protobuf", MIMEAppProto, protoMarshal},
+		{"text/csv", MIMETextCSV, csvMarshal},
+	}
+}
+
+// Default decoders, including the common media type aliases
+func decoders() []decoder {
+	jsonDecode := func(r io.Reader, i interface{}) error {
+		return json.NewDecoder(r).Decode(i)
+	}
+	xmlDecode := func(r io.Reader, i interface{}) error {
+		return xml.NewDecoder(r).Decode(i)
+	}
+	yamlDecode := func(r io.Reader, i interface{}) error {
+		return yaml.NewDecoder(r).Decode(i)
+	}
+	msgpackDecode := func(r io.Reader, i interface{}) error {
+		return msgpack.NewDecoder(r).Decode(i)
+	}
+	return []decoder{
+		{"application/json", jsonDecode},
+		{"application/xml", xmlDecode},
+		{"text/xml", xmlDecode},
+		{"application/x-yaml", yamlDecode},
+		{"application/yaml", yamlDecode},
+		{"text/yaml", yamlDecode},
+		{"application/msgpack", msgpackDecode},
+		{"application/x-msgpack", msgpackDecode},
+		{"application/x-protobuf", protoDecode},
+		{"application/protobuf", protoDecode},
+	}
+}
+
+// Find the decoder registered for a Content-Type header
+func findDecoder(header string, list []decoder) Decoder {
+	m := mime(header)
+	for _, d := range list {
+		if d.mime == m {
+			return d.decode
+		}
+	}
+	return nil
+}
+
+// Protocol buffers encoder
+func protoMarshal(i interface{}) ([]byte, error) {
+	m, ok := i.(proto.Message)
+	if !ok {
+		return nil, ErrNotProto
+	}
+	return proto.Marshal(m)
+}
+
+// Protocol buffers decoder
+func protoDecode(r io.Reader, i interface{}) error {
+	m, ok := i.(proto.Message)
+	if !ok {
+		return ErrNotProto
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, m)
+}
+
+// CSV encoder, accept a list of rows
+func csvMarshal(i interface{}) ([]byte, error) {
+	rows, ok := i.([][]string)
+	if !ok {
+		return nil, fmt.Errorf("csv: unsupported type %T", i)
+	}
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Mime return a media type without parameters
+func mime(s string) string {
+	return strings.ToLower(strings.TrimSpace(strings.Split(s, ";")[0]))
+}
+
+// ParseAccept return the accepted media types sorted by quality and specificity
+func parseAccept(header string) (list []accept) {
+	for _, part := range strings.Split(header, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		fields := strings.Split(part, ";")
+		a := accept{mime: mime(fields[0]), quality: 1}
+		for _, param := range fields[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.ToLower(kv[0]) == "q" {
+				q, err := strconv.ParseFloat(kv[1], 64)
+				if err != nil {
+					q = 0
+				}
+				a.quality = q
+				continue
+			}
+			a.params++
+		}
+		list = append(list, a)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].quality != list[j].quality {
+			return list[i].quality > list[j].quality
+		}
+		return specificity(list[i]) > specificity(list[j])
+	})
+	return
+}
+
+// Specificity of an accept entry, type/subtype;param > type/subtype > type/* > */*
+func specificity(a accept) int {
+	switch {
+	case a.mime == "*/*":
+		return 0
+	case strings.HasSuffix(a.mime, "/*"):
+		return 1
+	}
+	return 2 + a.params
+}
+
+// Match check if an accept entry match a media type
+func (a accept) match(m string) bool {
+	if a.mime == "*/*" || a.mime == m {
+		return true
+	}
+	if strings.HasSuffix(a.mime, "/*") {
+		return strings.HasPrefix(m, strings.TrimSuffix(a.mime, "*"))
+	}
+	return false
+}
+
+// Negotiate return the encoder that best match an Accept header
+func negotiate(header string, list []encoder) *encoder {
+	if len(list) == 0 {
+		return nil
+	}
+	if strings.TrimSpace(header) == "" {
+		return &list[0]
+	}
+	accepted := parseAccept(header)
+	// media types refused with q=0, by the most specific matching entry as text/*;q=0
+	refused := func(m string) bool {
+		best := -1
+		quality := 1.0
+		for _, a := range accepted {
+			if a.match(m) && specificity(a) > best {
+				best, quality = specificity(a), a.quality
+			}
+		}
+		return best >= 0 && quality == 0
+	}
+	for _, a := range accepted {
+		if a.quality <= 0 {
+			continue
+		}
+		for i := range list {
+			if a.match(list[i].mime) && !refused(list[i].mime) {
+				return &list[i]
+			}
+		}
+	}
+	return nil
+}
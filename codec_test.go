@@ -0,0 +1,103 @@
+package fresh
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestCodec_MsgPack(t *testing.T) {
+	type data struct {
+		Name  string `msgpack:"name"`
+		Count int    `msgpack:"count"`
+	}
+	f := setup()
+	f.POST("/msgpack", func(c Context) error {
+		var d data
+		if err := c.Request().MsgPack(&d); err != nil {
+			return c.Response().Error(http.StatusBadRequest, err)
+		}
+		d.Count++
+		return c.Response().MsgPack(http.StatusOK, d)
+	})
+	body, _ := msgpack.Marshal(data{"fresh", 1})
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/msgpack", bytes.NewReader(body))
+	req.Header.Set(ContentType, MIMEAppMsgPack)
+	f.router.ServeHTTP(rec, req)
+	var d data
+	if err := msgpack.Unmarshal(rec.Body.Bytes(), &d); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || rec.Header().Get(ContentType) != MIMEAppMsgPack || d != (data{"fresh", 2}) {
+		t.Fatal("Unexpected msgpack response", rec.Code, rec.Header().Get(ContentType), d)
+	}
+}
+
+func TestCodec_Protobuf(t *testing.T) {
+	f := setup()
+	f.POST("/proto", func(c Context) error {
+		m := new(wrapperspb.StringValue)
+		if err := c.Request().Protobuf(m); err != nil {
+			return c.Response().Error(http.StatusBadRequest, err)
+		}
+		return c.Response().Protobuf(http.StatusOK, wrapperspb.String(m.Value+"!"))
+	})
+	body, _ := proto.Marshal(wrapperspb.String("fresh"))
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/proto", bytes.NewReader(body))
+	req.Header.Set(ContentType, MIMEAppProto)
+	f.router.ServeHTTP(rec, req)
+	m := new(wrapperspb.StringValue)
+	if err := proto.Unmarshal(rec.Body.Bytes(), m); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || rec.Header().Get(ContentType) != MIMEAppProto || m.Value != "fresh!" {
+		t.Fatal("Unexpected protobuf response", rec.Code, rec.Header().Get(ContentType), m.Value)
+	}
+	if _, err := protoMarshal(struct{}{}); !errors.Is(err, ErrNotProto) {
+		t.Fatal("Expected a not proto error", err)
+	}
+}
+
+func TestResponse_CSVStream(t *testing.T) {
+	f := setup()
+	f.config.Gzip = &Gzip{}
+	f.GET("/csv", func(c Context) error {
+		return c.Response().CSVStream(http.StatusOK, func(yield func([]string) error) error {
+			for i := 0; i < 250; i++ {
+				if err := yield([]string{strconv.Itoa(i)}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/csv", nil)
+	req.Header.Set(AcceptEncoding, MIMEGzip)
+	f.router.ServeHTTP(rec, req)
+	if rec.Header().Get(ContentEncoding) != MIMEGzip || rec.Header().Get(ContentType) != MIMETextCSV {
+		t.Fatal("Expected a compressed csv stream", rec.Header())
+	}
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(b, []byte("\n")); n != 250 || !bytes.HasSuffix(b, []byte("249\n")) {
+		t.Fatal("Unexpected csv rows", n)
+	}
+}
@@ -1,21 +1,18 @@
 package fresh
 
 import (
-	"bytes"
 	"compress/gzip"
-	"crypto/tls"
 	"fmt"
 	"github.com/fatih/color"
 	"golang.org/x/crypto/acme/autocert"
 	"gopkg.in/yaml.v2"
-	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strconv"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -27,39 +24,72 @@ const (
 
 type (
 	Config struct {
-		*fresh   `yaml:"-"`
-		request  *request          `yaml:"-"`                 // request config
-		handlers []HandlerFunc     `yaml:"-"`                 // handlers array
-		Host     string            `yaml:"host,omitempty"`    // server host
-		Port     int               `yaml:"port,omitempty"`    // server port
-		Logs     Logs              `yaml:"logs,omitempty"`    // server logs
-		TSL      *TSL              `yaml:"tsl,omitempty"`     // tsl options
-		Gzip     *Gzip             `yaml:"gzip,omitempty"`    // gzip Config
-		CORS     *CORS             `yaml:"cors,omitempty"`    // cors options
-		Limit    *Limit            `yaml:"limit,omitempty"`   // limit options
-		Default  []string          `yaml:"default,omitempty"` // default static files (index.html or main.html and so on)
-		Statics  map[string]string `yaml:"static,omitempty"`  // serve static files
-		Banner   bool              `yaml:"banner,omitempty"`  // enable / disable startup banner
-		Options  bool              `yaml:"options,omitempty"` // accept all OPTIONS requests
-		Router   *Router           `yaml:"router,omitempty"`  // router related config
+		*fresh     `yaml:"-"`
+		request    *request          `yaml:"-"`                    // request config
+		before     []HandlerFunc     `yaml:"-"`                    // handlers array run before the route middleware
+		handlers   []HandlerFunc     `yaml:"-"`                    // handlers array
+		encoders   []encoder         `yaml:"-"`                    // response encoders
+		decoders   []decoder         `yaml:"-"`                    // request decoders
+		Host       string            `yaml:"host,omitempty"`       // server host
+		Port       int               `yaml:"port,omitempty"`       // server port
+		Logs       Logs              `yaml:"logs,omitempty"`       // server logs
+		TSL        *TSL              `yaml:"tsl,omitempty"`        // tsl options
+		Gzip       *Gzip             `yaml:"gzip,omitempty"`       // gzip Config
+		Cache      *Cache            `yaml:"cache,omitempty"`      // cache options
+		CORS       *CORS             `yaml:"cors,omitempty"`       // cors options
+		Security   *Security         `yaml:"security,omitempty"`   // security headers
+		Limit      *Limit            `yaml:"limit,omitempty"`      // limit options
+		RequestID  *RequestID        `yaml:"request-id,omitempty"` // request id generation
+		Override   *Override         `yaml:"override,omitempty"`   // http method override
+		Firewall   *Firewall         `yaml:"firewall,omitempty"`   // ip allow and deny lists
+		Proxy      *Proxy            `yaml:"proxy,omitempty"`      // trusted proxies
+		RateLimit  *RateLimit        `yaml:"ratelimit,omitempty"`  // rate limit options
+		AccessLog  *AccessLog        `yaml:"access-log,omitempty"` // access log options
+		Metrics    *Metrics          `yaml:"metrics,omitempty"`    // prometheus metrics
+		Tracing    *Tracing          `yaml:"tracing,omitempty"`    // request spans
+		Health     *Health           `yaml:"health,omitempty"`     // health probes
+		Shutdown   *Shutdown         `yaml:"shutdown,omitempty"`   // graceful shutdown
+		Server     *HTTPServer       `yaml:"server,omitempty"`     // http server timeouts and limits
+		Default    []string          `yaml:"default,omitempty"`    // default static files (index.html or main.html and so on)
+		Statics    map[string]string `yaml:"static,omitempty"`     // serve static files
+		Banner     bool              `yaml:"banner,omitempty"`     // enable / disable startup banner
+		ETag       bool              `yaml:"etag,omitempty"`       // enable / disable weak etag generation
+		Options    bool              `yaml:"options,omitempty"`    // accept all OPTIONS requests
+		Router     *Router           `yaml:"router,omitempty"`     // router related config
+		Authorizer Authorizer        `yaml:"-"`                    // route requirements authorizer
+		Logger     Logger            `yaml:"-"`                    // framework logger, built from logs when nil
+		logOnce    sync.Once
+		logFile    *rotateWriter
 	}
 
 	Logs struct {
-		File   bool `yaml:"file,omitempty"`
-		Stdout bool `yaml:"stdout,omitempty"`
+		File       bool          `yaml:"file,omitempty"`
+		Stdout     bool          `yaml:"stdout,omitempty"`
+		Level      string        `yaml:"level,omitempty"`    // debug, info, warn or error
+		Format     string        `yaml:"format,omitempty"`   // console or json
+		Path       string        `yaml:"path,omitempty"`     // logs file path
+		MaxSize    string        `yaml:"max-size,omitempty"` // rotate when the file reach a size, as 10MB
+		Rotate     time.Duration `yaml:"rotate,omitempty"`   // rotate every interval, as 24h
+		MaxBackups int           `yaml:"max-backups,omitempty"`
+		MaxAge     time.Duration `yaml:"max-age,omitempty"`
+		Compress   bool          `yaml:"compress,omitempty"` // gzip the rotated files
 	}
 
 	Router struct {
 		Print bool `yaml:"print,omitempty"`
 	}
 
+	Override struct {
+		Methods []string `yaml:"methods,omitempty"`
+	}
+
 	Limit struct {
 		Body   string `yaml:"body,omitempty"`
 		Header string `yaml:"header,omitempty"`
 	}
 
 	Gzip struct {
-		writer         io.Writer
+		writer         *gzip.Writer
 		responseWriter http.ResponseWriter
 		Level          int      `yaml:"level,omitempty"`
 		MinSize        int      `yaml:"size,omitempty"`
@@ -68,84 +98,160 @@ type (
 	}
 
 	TSL struct {
-		Force bool   `yaml:"force,omitempty"`
-		Crt   string `yaml:"crt,omitempty"`
-		Key   string `yaml:"key,omitempty"`
+		manager    *autocert.Manager
+		Force      bool          `yaml:"force,omitempty"`    // redirect the http requests to https
+		Redirect   string        `yaml:"redirect,omitempty"` // http redirect address, default host:80
+		Crt        string        `yaml:"crt,omitempty"`
+		Key        string        `yaml:"key,omitempty"`
+		Reload     time.Duration `yaml:"reload,omitempty"` // certificate files check interval, default 10s, negative to disable
+		Cache      string        `yaml:"cache,omitempty"`  // automatic certificates cache dir
+		MinVersion string        `yaml:"min-version,omitempty"`
+		Ciphers    []string      `yaml:"ciphers,omitempty"`
+		Insecure   bool          `yaml:"insecure-ciphers,omitempty"` // allow the insecure cipher suites
 	}
 
 	CORS struct {
-		Origins     []string `yaml:"origins,omitempty"`
-		Methods     []string `yaml:"methods,omitempty"`
-		Headers     []string `yaml:"headers,omitempty"`
-		Expose      []string `yaml:"expose,omitempty"`
-		MaxAge      int      `yaml:"maxage,omitempty"`
-		Credentials bool     `yaml:"credentials,omitempty"`
-		Filters     []Filter `yaml:"-,omitempty"`
+		once        sync.Once
+		patterns    []*regexp.Regexp
+		Origins     []string                   `yaml:"origins,omitempty"`
+		Patterns    []string                   `yaml:"patterns,omitempty"`
+		Methods     []string                   `yaml:"methods,omitempty"`
+		Headers     []string                   `yaml:"headers,omitempty"`
+		Expose      []string                   `yaml:"expose,omitempty"`
+		MaxAge      int                        `yaml:"maxage,omitempty"`
+		Credentials bool                       `yaml:"credentials,omitempty"`
+		AllowOrigin func(Context, string) bool `yaml:"-"`
+		Filters     []Filter                   `yaml:"-,omitempty"`
 	}
 
 	Security struct {
-		XSS            string `yaml:"xss,omitempty"`
-		HSTS           int    `yaml:"hsts,omitempty"`
-		XDNS           bool   `yaml:"x-dns,omitempty"`
-		CSFR           string `yaml:"csfr,omitempty"`
-		XFrame         string `yaml:"x-frame,omitempty"`
-		XContentType   string `yaml:"x-content-type,omitempty"`
-		ReferrerPolicy string `yaml:"referrer-policy,omitempty"`
+		XSS            string              `yaml:"xss,omitempty"`
+		HSTS           int                 `yaml:"hsts,omitempty"`
+		XDNS           bool                `yaml:"x-dns,omitempty"`
+		CSRF           *CSRF               `yaml:"csrf,omitempty"`
+		XFrame         string              `yaml:"x-frame,omitempty"`
+		XContentType   string              `yaml:"x-content-type,omitempty"`
+		ReferrerPolicy string              `yaml:"referrer-policy,omitempty"`
+		CSP            *CSP                `yaml:"csp,omitempty"`
+		Permissions    map[string][]string `yaml:"permissions-policy,omitempty"`
+		COOP           string              `yaml:"cross-origin-opener-policy,omitempty"`
+		COEP           string              `yaml:"cross-origin-embedder-policy,omitempty"`
+		CORP           string              `yaml:"cross-origin-resource-policy,omitempty"`
 	}
 
-	Filter func(Context) bool
-)
+	CSRF struct {
+		Cookie   string      `yaml:"cookie,omitempty"`
+		Header   string      `yaml:"header,omitempty"`
+		Field    string      `yaml:"field,omitempty"`
+		MaxAge   int         `yaml:"maxage,omitempty"`
+		Secure   bool        `yaml:"secure,omitempty"`
+		SameSite string      `yaml:"samesite,omitempty"`
+		Exempt   []string    `yaml:"exempt,omitempty"`
+		Filters  []Filter    `yaml:"-"`
+		Session  CSRFSession `yaml:"-"`
+	}
 
-// TSL with auto cert file
-func (c *Config) tsl() *Config {
-	certManager := autocert.Manager{
-		Prompt:     autocert.AcceptTOS,
-		HostPolicy: autocert.HostWhitelist(c.Host),
+	// CSRFSession save the synchronizer token in a server side session instead of a cookie
+	CSRFSession interface {
+		Get(Context) string
+		Set(Context, string)
 	}
-	c.server.TLSConfig = &tls.Config{
-		GetCertificate: certManager.GetCertificate,
+
+	CSP struct {
+		Directives map[string][]string `yaml:"directives,omitempty"`
+		Nonce      bool                `yaml:"nonce,omitempty"`
+		ReportOnly bool                `yaml:"report-only,omitempty"`
 	}
-	return c
-}
+
+	// Filter return true to skip a config handler for a request
+	Filter func(Context) bool
+)
 
 // Init set default server config
 func (c *Config) init(f *fresh) *Config {
 	c.Banner = true
+	c.ETag = true
 	c.Logs.Stdout = true
+	c.Cache = &Cache{TTL: cacheTTL, Size: cacheSize}
+	c.Shutdown = &Shutdown{Timeout: 5 * time.Second, Signals: true}
+	c.Server = &HTTPServer{
+		ReadTimeout:       readTimeout,
+		ReadHeaderTimeout: readHeaderTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+	c.encoders = encoders()
+	c.decoders = decoders()
+	// add handlers run before the route middleware
+	c.before = append(c.before,
+		// rate limit
+		func(context Context) error {
+			// a limit by user waits for the route authentication
+			if c.RateLimit != nil && c.RateLimit.Key != "user" {
+				return c.RateLimit.take(context, "global")
+			}
+			return nil
+		},
+		// limit
+		func(context Context) error {
+			body := ""
+			if c.Limit != nil {
+				body = c.Limit.Body
+			}
+			return context.Request().limit(size(body))
+		},
+		// cors
+		func(context Context) error {
+			if policy := corsPolicy(context, c.CORS); policy != nil {
+				policy.headers(context)
+			}
+			return nil
+		},
+		// csrf
+		func(context Context) error {
+			if c.Security != nil && c.Security.CSRF != nil {
+				return c.Security.CSRF.check(context)
+			}
+			return nil
+		},
+	)
 	// add handlers
 	c.handlers = append(c.handlers,
+		// conditional
+		func(context Context) error {
+			context.Response().conditional(c.ETag)
+			return nil
+		},
 		// gzip
 		func(context Context) error {
 			if c.Gzip != nil {
 				reply := context.Response().get()
-				// check buffer length
-				if len(reply.response) >= c.Gzip.MinSize {
+				// check buffer length, streams are always compressed
+				if reply.code != http.StatusNotModified && (reply.stream != nil || len(reply.response) >= c.Gzip.MinSize) {
 					r := context.Request().Get()
 					w := context.Response().Get()
 					if strings.Contains(r.Header.Get(AcceptEncoding), MIMEGzip) {
-						ct := r.Header.Get(ContentType)
-						if len(ct) == 0 || contain(ct, c.Gzip.Types) {
-							if len(ct) == 0 {
-								// detect content type by reading response
-								w.Header().Set(ContentType, http.DetectContentType(reply.response))
+						ct := w.Header().Get(ContentType)
+						if len(ct) == 0 && reply.stream == nil {
+							// detect content type by reading response
+							ct = http.DetectContentType(reply.response)
+							w.Header().Set(ContentType, ct)
+						}
+						if len(c.Gzip.Types) == 0 || contain(mime(ct), c.Gzip.Types) {
+							// new writer
+							level := gzip.DefaultCompression
+							if c.Gzip.Level >= gzip.NoCompression && c.Gzip.Level <= gzip.BestCompression {
+								level = c.Gzip.Level
+							}
+							gz, err := gzip.NewWriterLevel(w, level)
+							if err != nil {
+								return err
 							}
 							// set header
 							w.Header().Set(ContentEncoding, MIMEGzip)
+							vary(w.Header(), AcceptEncoding)
 							// del length if exist
 							w.Header().Del(ContentLength)
-							// new writer
-							gz := &gzip.Writer{}
-							defer gz.Close()
-							if c.Gzip.Level >= gzip.NoCompression && c.Gzip.Level <= gzip.BestCompression {
-								var err error
-								gz, err = gzip.NewWriterLevel(w, c.Gzip.Level)
-								if err != nil {
-									context.Writer(w)
-									return err
-								}
-							} else {
-								gz = gzip.NewWriter(w)
-							}
+							// closed once the response is written
 							context.Writer(&Gzip{writer: gz, responseWriter: w})
 						}
 					}
@@ -153,65 +259,10 @@ func (c *Config) init(f *fresh) *Config {
 			}
 			return nil
 		},
-		// cors
-		func(context Context) error {
-			if c.CORS != nil {
-				w := context.Response().Get()
-				// Allow origins
-				if len(c.CORS.Origins) > 0 {
-					for _, h := range c.CORS.Origins {
-						if h == "*" {
-							w.Header().Set(AccessControlAllowOrigin, h)
-							break
-						} else if h == context.Request().Get().Header.Get("Origin") {
-							w.Header().Set(AccessControlAllowOrigin, h)
-						}
-					}
-				}
-				// Allowed Headers
-				if len(c.CORS.Headers) > 0 {
-					w.Header().Set(AccessControlAllowHeaders, strings.Join(c.CORS.Headers[:], ","))
-				}
-				// Allowed Methods
-				if len(c.CORS.Methods) > 0 {
-					w.Header().Set(AccessControlAllowMethods, strings.Join(c.CORS.Methods[:], ","))
-				}
-				// Allow credentials
-				if c.CORS.Credentials {
-					w.Header().Set(AccessControlAllowCredentials, "true")
-				}
-				// Expose headers
-				if len(c.CORS.Expose) > 0 {
-					w.Header().Set(AccessControlExposes, strings.Join(c.CORS.Expose[:], ","))
-				}
-				// Max age
-				if c.CORS.MaxAge > 0 {
-					w.Header().Set(AccessControlMaxAge, strconv.Itoa(c.CORS.MaxAge))
-				}
-			}
-			return nil
-		},
 		// tsl
 		func(context Context) error {
 			return nil
 		},
-		// limit
-		func(context Context) error {
-			if c.Limit != nil {
-				req := context.Request().Get()
-				// content length
-				if req.ContentLength > size(c.Limit.Body) {
-					return nil
-				}
-				// read body
-				buf := new(bytes.Buffer)
-				l, err := buf.ReadFrom(req.Body)
-				if err != nil || l > size(c.Limit.Body) {
-					return nil
-				}
-			}
-			return nil
-		},
 		// static
 		func(context Context) error {
 			return nil
@@ -220,6 +271,32 @@ func (c *Config) init(f *fresh) *Config {
 	return c
 }
 
+// Encoder register a response encoder for a media type, used by content negotiation
+func (c *Config) Encoder(content string, e Encoder) *Config {
+	m := mime(content)
+	for i := range c.encoders {
+		if c.encoders[i].mime == m {
+			c.encoders[i] = encoder{m, content, e}
+			return c
+		}
+	}
+	c.encoders = append(c.encoders, encoder{m, content, e})
+	return c
+}
+
+// Decoder register a request body decoder for a media type, used by request binding
+func (c *Config) Decoder(content string, d Decoder) *Config {
+	m := mime(content)
+	for i := range c.decoders {
+		if c.decoders[i].mime == m {
+			c.decoders[i].decode = d
+			return c
+		}
+	}
+	c.decoders = append(c.decoders, decoder{m, d})
+	return c
+}
+
 // Read server Config from a file
 func (c *Config) read(path string) error {
 	content, err := ioutil.ReadFile(filepath.Join(path, file))
@@ -250,25 +327,6 @@ func (c *Config) banner() {
 	}
 }
 
-// TODO improve logs layout
-// Log print if config logs is enabled
-func (c *Config) log(i ...interface{}) {
-	if c.Logs.Stdout {
-		log.Println(i...)
-	}
-	if c.Logs.File {
-		f, err := os.OpenFile(logs, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
-		if err != nil {
-			panic(err)
-		}
-		defer f.Close()
-		msg := append([]interface{}{time.Now().Format(time.RFC3339)}, i...)
-		if _, err = f.WriteString(fmt.Sprintln(msg...)); err != nil {
-			panic(err)
-		}
-	}
-}
-
 // WriteHeader set a gzip header
 func (g *Gzip) WriteHeader(i int) {
 	g.responseWriter.WriteHeader(i)
@@ -284,3 +342,16 @@ func (g *Gzip) Write(b []byte) (int, error) {
 	// check buffer
 	return g.writer.Write(b)
 }
+
+// Flush the compressed data to the client
+func (g *Gzip) Flush() {
+	g.writer.Flush()
+	if f, ok := g.responseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close end the gzip stream
+func (g *Gzip) Close() error {
+	return g.writer.Close()
+}
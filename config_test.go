@@ -48,3 +48,11 @@ func TestWrite(t *testing.T) {
 	}
 	os.Remove(path)
 }
+
+func TestSize(t *testing.T) {
+	for s, expected := range map[string]int64{"": 0, "512": 512, "10K": 10 * K, "5MB": 5 * M, "1 gb": G, "2T": 2 * T, "10X": 0} {
+		if result := size(s); result != expected {
+			t.Error("Size of", s, "returned", result, "instead of", expected)
+		}
+	}
+}
@@ -18,6 +18,10 @@ const (
 	MIMEAppJSON    = "application/json" + ";" + UTF8
 	MIMEAppJS      = "application/javascript" + ";" + UTF8
 	MIMEAppXML     = "application/xml" + ";" + UTF8
+	MIMEAppYAML    = "application/x-yaml" + ";" + UTF8
+	MIMEAppMsgPack = "application/msgpack"
+	MIMEAppProto   = "application/x-protobuf"
+	MIMETextCSV    = "text/csv" + ";" + UTF8
 	MIMEUrlencoded = "application/x-www-form-urlencoded"
 	MIMEMultipart  = "multipart/form-data"
 	MIMETextHTML   = "text/html" + ";" + UTF8
@@ -31,10 +35,10 @@ const (
 	AccessControlMaxAge           = "Access-Control-Max-Age"
 	AccessControlAllowOrigin      = "Access-Control-Allow-Origin"
 	AccessControlAllowMethods     = "Access-Control-Allow-Methods"
-	AccessControlAllows           = "Access-Control-Allow-s"
+	AccessControlAllows           = "Access-Control-Allow-Headers"
 	AccessControlRequestMethod    = "Access-Control-Request-Method"
-	AccessControlExposes          = "Access-Control-Expose-s"
-	AccessControlRequests         = "Access-Control-Request-s"
+	AccessControlExposes          = "Access-Control-Expose-Headers"
+	AccessControlRequests         = "Access-Control-Request-Headers"
 	AccessControlAllowCredentials = "Access-Control-Allow-Credentials"
 	AccessControlAllowHeaders     = "Access-Control-Allow-Headers"
 )
@@ -42,22 +46,35 @@ const (
 // Request
 const (
 	Accept              = "Accept"
+	Age                 = "Age"
 	AcceptEncoding      = "Accept-Encoding"
 	Allow               = "Allow"
 	Authorization       = "Authorization"
+	CacheControl        = "Cache-Control"
 	ContentDisposition  = "Content-Disposition"
 	ContentEncoding     = "Content-Encoding"
 	ContentLength       = "Content-Length"
 	ContentType         = "Content-Type"
 	Cookie              = "Cookie"
 	SetCookie           = "Set-Cookie"
+	ETag                = "ETag"
+	IfMatch             = "If-Match"
+	IfNoneMatch         = "If-None-Match"
 	IfModifiedSince     = "If-Modified-Since"
+	IfUnmodifiedSince   = "If-Unmodified-Since"
 	LastModified        = "Last-Modified"
 	Location            = "Location"
+	RetryAfter          = "Retry-After"
+	RateLimitLimit      = "RateLimit-Limit"
+	RateLimitRemaining  = "RateLimit-Remaining"
+	RateLimitReset      = "RateLimit-Reset"
+	RateLimitPolicy     = "RateLimit-Policy"
 	Upgrade             = "Upgrade"
 	Vary                = "Vary"
 	WWWAuthenticate     = "WWW-Authenticate"
+	Forwarded           = "Forwarded"
 	XForwardedFor       = "X-Forwarded-For"
+	XForwardedHost      = "X-Forwarded-Host"
 	XForwardedProto     = "X-Forwarded-Proto"
 	XForwardedProtocol  = "X-Forwarded-Protocol"
 	XForwardedSsl       = "X-Forwarded-Ssl"
@@ -65,10 +82,39 @@ const (
 	XHTTPMethodOverride = "X-HTTP-Method-Override"
 	XRealIP             = "X-Real-IP"
 	XRequestID          = "X-Request-ID"
+	XCSRFToken          = "X-CSRF-Token"
+	Traceparent         = "Traceparent"
+	Tracestate          = "Tracestate"
 	Server              = "Server"
 	Origin              = "Origin"
 )
 
+// Security
+const (
+	XXSSProtection                  = "X-XSS-Protection"
+	XFrameOptions                   = "X-Frame-Options"
+	XContentTypeOptions             = "X-Content-Type-Options"
+	XDNSPrefetchControl             = "X-DNS-Prefetch-Control"
+	ReferrerPolicy                  = "Referrer-Policy"
+	StrictTransportSecurity         = "Strict-Transport-Security"
+	ContentSecurityPolicy           = "Content-Security-Policy"
+	ContentSecurityPolicyReportOnly = "Content-Security-Policy-Report-Only"
+	PermissionsPolicy               = "Permissions-Policy"
+	CrossOriginOpenerPolicy         = "Cross-Origin-Opener-Policy"
+	CrossOriginEmbedderPolicy       = "Cross-Origin-Embedder-Policy"
+	CrossOriginResourcePolicy       = "Cross-Origin-Resource-Policy"
+)
+
+// Context keys
+const (
+	ContextNonce     = "fresh.nonce"
+	ContextCSRF      = "fresh.csrf"
+	ContextClaims    = "fresh.claims"
+	ContextPrincipal = "fresh.principal"
+	ContextRequestID = "fresh.request-id"
+	ContextSpan      = "fresh.span"
+)
+
 // Encoding chartset
 const (
 	UTF8     = "charset=UTF-8"
@@ -0,0 +1,141 @@
+package fresh
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Preflight check if a request is a CORS preflight request
+func preflight(r *http.Request) bool {
+	return r.Method == "OPTIONS" && r.Header.Get(Origin) != "" && r.Header.Get(AccessControlRequestMethod) != ""
+}
+
+// CorsPolicy return the route policy or the global one, nil when skipped by a filter
+func corsPolicy(c Context, global *CORS) *CORS {
+	policy := global
+	if ctx, ok := c.(*context); ok && ctx.handler != nil && ctx.handler.cors != nil {
+		policy = ctx.handler.cors
+	}
+	if policy == nil {
+		return nil
+	}
+	for _, f := range policy.Filters {
+		if f(c) {
+			return nil
+		}
+	}
+	return policy
+}
+
+// Any check if all the origins are allowed
+func (p *CORS) any() bool {
+	return contain("*", p.Origins)
+}
+
+// Allowed check an origin against origins, wildcard subdomains, patterns and the allow func
+func (p *CORS) allowed(c Context, origin string) bool {
+	p.once.Do(func() {
+		for _, s := range p.Patterns {
+			re, err := regexp.Compile(s)
+			if err != nil {
+				c.Logger().Error("cors invalid origin pattern", "pattern", s, "error", err)
+				continue
+			}
+			p.patterns = append(p.patterns, re)
+		}
+		if p.any() && p.Credentials {
+			c.Logger().Warn("cors wildcard origin ignored, credentials require explicit origins")
+		}
+	})
+	for _, o := range p.Origins {
+		// credentials are never shared with any origin
+		if (o == "*" && !p.Credentials) || strings.EqualFold(o, origin) {
+			return true
+		}
+		// wildcard subdomain as https://*.example.com
+		if i := strings.Index(o, "*."); i >= 0 {
+			prefix, suffix := o[:i], o[i+1:]
+			if len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		}
+	}
+	for _, re := range p.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return p.AllowOrigin != nil && p.AllowOrigin(c, origin)
+}
+
+// Headers set the CORS headers of an actual or a preflight request
+func (p *CORS) headers(c Context) {
+	req := c.Request().Get()
+	h := c.Response().Get().Header()
+	origin := req.Header.Get(Origin)
+	isPreflight := preflight(req)
+	if isPreflight {
+		vary(h, AccessControlRequestMethod, AccessControlRequests)
+	}
+	// the response change with the origin unless every origin get the same answer
+	if !p.any() || p.Credentials {
+		vary(h, Origin)
+	}
+	if origin == "" || !p.allowed(c, origin) {
+		return
+	}
+	if isPreflight {
+		method := req.Header.Get(AccessControlRequestMethod)
+		if len(p.Methods) > 0 {
+			allowed := false
+			for _, m := range p.Methods {
+				allowed = allowed || strings.EqualFold(m, method)
+			}
+			if !allowed {
+				return
+			}
+			h.Set(AccessControlAllowMethods, strings.Join(p.Methods, ","))
+		} else {
+			h.Set(AccessControlAllowMethods, method)
+		}
+		if len(p.Headers) > 0 {
+			h.Set(AccessControlAllowHeaders, strings.Join(p.Headers, ","))
+		} else if requested := req.Header.Get(AccessControlRequests); requested != "" {
+			h.Set(AccessControlAllowHeaders, requested)
+		}
+		if p.MaxAge > 0 {
+			h.Set(AccessControlMaxAge, strconv.Itoa(p.MaxAge))
+		}
+	} else if len(p.Expose) > 0 {
+		h.Set(AccessControlExposes, strings.Join(p.Expose, ","))
+	}
+	if p.any() && !p.Credentials {
+		h.Set(AccessControlAllowOrigin, "*")
+	} else {
+		h.Set(AccessControlAllowOrigin, origin)
+	}
+	if p.Credentials {
+		h.Set(AccessControlAllowCredentials, "true")
+	}
+}
+
+// Preflight return the handler answering a preflight request, nil if CORS isn't enabled for the route
+func (r *router) preflight(route *route, request *http.Request) *handler {
+	policy := r.config.CORS
+	if target := route.getHandler(request.Header.Get(AccessControlRequestMethod)); target != nil && target.cors != nil {
+		policy = target.cors
+	}
+	if policy == nil {
+		return nil
+	}
+	return &handler{
+		route:  route,
+		method: "OPTIONS",
+		cors:   policy,
+		ctrl: func(c Context) error {
+			return c.Response().Code(http.StatusNoContent)
+		},
+	}
+}
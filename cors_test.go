@@ -0,0 +1,54 @@
+package fresh
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS_Preflight(t *testing.T) {
+	f := setup()
+	f.config.CORS = &CORS{Origins: []string{"https://*.example.com"}, Patterns: []string{`^https://app\d+\.test$`}, Methods: []string{"GET", "PUT"}, MaxAge: 600}
+	f.PUT("/items/:id", func(c Context) error {
+		return c.Response().Code(http.StatusNoContent)
+	})
+	f.Group("/public").CORS(&CORS{Origins: []string{"*"}}).GET("/feed", func(c Context) error {
+		return c.Response().Code(http.StatusOK)
+	})
+	f.Group("/private").CORS(&CORS{Origins: []string{"*", "https://trusted.org"}, Patterns: []string{"("}, Credentials: true}).GET("/feed", func(c Context) error {
+		return c.Response().Code(http.StatusOK)
+	})
+	tests := []struct {
+		method  string
+		path    string
+		origin  string
+		request string
+		code    int
+		allow   string
+	}{
+		{"OPTIONS", "/items/1", "https://api.example.com", "PUT", http.StatusNoContent, "https://api.example.com"},
+		{"OPTIONS", "/items/1", "https://app42.test", "PUT", http.StatusNoContent, "https://app42.test"},
+		{"OPTIONS", "/items/1", "https://example.com", "PUT", http.StatusNoContent, ""},
+		{"OPTIONS", "/items/1", "https://api.example.com", "DELETE", http.StatusNoContent, ""},
+		{"PUT", "/items/1", "https://api.example.com", "", http.StatusNoContent, "https://api.example.com"},
+		{"OPTIONS", "/public/feed", "https://other.org", "GET", http.StatusNoContent, "*"},
+		{"OPTIONS", "/private/feed", "https://other.org", "GET", http.StatusNoContent, ""},
+		{"OPTIONS", "/private/feed", "https://trusted.org", "GET", http.StatusNoContent, "https://trusted.org"},
+		{"OPTIONS", "/missing", "https://api.example.com", "GET", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, test.path, nil)
+		req.Header.Set(Origin, test.origin)
+		if test.request != "" {
+			req.Header.Set(AccessControlRequestMethod, test.request)
+		}
+		f.router.ServeHTTP(rec, req)
+		if rec.Code != test.code {
+			t.Fatal(test.method, test.path, "returned", rec.Code, "instead of", test.code)
+		}
+		if rec.Header().Get(AccessControlAllowOrigin) != test.allow {
+			t.Fatal(test.method, test.path, "allowed", rec.Header().Get(AccessControlAllowOrigin), "instead of", test.allow)
+		}
+	}
+}
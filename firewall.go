@@ -0,0 +1,82 @@
+package fresh
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// Firewall config, denied addresses are rejected first then, if set, only allowed addresses pass
+type Firewall struct {
+	mu     sync.RWMutex
+	parsed bool
+	allow  []*net.IPNet
+	deny   []*net.IPNet
+	Allow  []string `yaml:"allow,omitempty"`
+	Deny   []string `yaml:"deny,omitempty"`
+}
+
+// ErrFirewall returned when a client address is rejected
+var ErrFirewall = errors.New("address not allowed")
+
+// Reload replace the allow and deny lists at runtime, lists are unchanged on error
+func (fw *Firewall) Reload(allow, deny []string) error {
+	a, err := parseCIDR(allow)
+	if err != nil {
+		return err
+	}
+	d, err := parseCIDR(deny)
+	if err != nil {
+		return err
+	}
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.Allow, fw.Deny = allow, deny
+	fw.allow, fw.deny, fw.parsed = a, d, true
+	return nil
+}
+
+// Allowed check an address against the deny and allow lists
+func (fw *Firewall) allowed(ip net.IP) bool {
+	fw.mu.RLock()
+	if !fw.parsed {
+		fw.mu.RUnlock()
+		fw.mu.Lock()
+		if !fw.parsed {
+			fw.allow, _ = parseCIDR(fw.Allow)
+			fw.deny, _ = parseCIDR(fw.Deny)
+			fw.parsed = true
+		}
+		fw.mu.Unlock()
+		fw.mu.RLock()
+	}
+	defer fw.mu.RUnlock()
+	if ip == nil {
+		return false
+	}
+	for _, n := range fw.deny {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	if len(fw.allow) == 0 {
+		return true
+	}
+	for _, n := range fw.allow {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check the client address, rejected addresses are logged
+func (fw *Firewall) check(c Context) error {
+	ip := c.Request().RealIP()
+	if fw.allowed(net.ParseIP(ip)) {
+		return nil
+	}
+	c.Logger().Warn("firewall rejected", "ip", ip, "method", c.Request().Method(), "uri", c.Request().URL().RequestURI())
+	return c.Response().Error(http.StatusForbidden, ErrFirewall)
+}
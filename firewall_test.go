@@ -0,0 +1,45 @@
+package fresh
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFirewall(t *testing.T) {
+	f := setup()
+	f.config.Logs.Stdout = false
+	fw := &Firewall{Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.0.0.66"}}
+	f.Group("/internal").Firewall(fw).GET("/status", func(c Context) error {
+		return c.Response().Code(http.StatusOK)
+	})
+	check := func(remote string, code int) {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/internal/status", nil)
+		req.RemoteAddr = remote + ":1234"
+		f.router.ServeHTTP(rec, req)
+		if rec.Code != code {
+			t.Fatal("Address", remote, "returned", rec.Code, "instead of", code)
+		}
+	}
+	check("10.1.2.3", http.StatusOK)
+	check("10.0.0.66", http.StatusForbidden)
+	check("203.0.113.9", http.StatusForbidden)
+	if err := fw.Reload([]string{"203.0.113.0/24"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	check("203.0.113.9", http.StatusOK)
+	check("10.1.2.3", http.StatusForbidden)
+	if err := fw.Reload([]string{"invalid"}, nil); err == nil {
+		t.Fatal("Expected an invalid address error")
+	}
+	// the config firewall cover unmatched paths
+	f.config.Firewall = &Firewall{Deny: []string{"203.0.113.0/24"}}
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/missing", nil)
+	req.RemoteAddr = "203.0.113.9:1234"
+	f.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatal("Expected a denied unmatched path, returned", rec.Code)
+	}
+}
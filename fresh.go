@@ -2,10 +2,8 @@ package fresh
 
 import (
 	httpContext "context"
-	"log"
 	"net/http"
 	"os"
-	"time"
 
 	"net"
 	"os/signal"
@@ -16,15 +14,21 @@ import (
 // Main Fresh structure
 type (
 	fresh struct {
-		config *Config
-		router *router
-		server *http.Server
+		config    *Config
+		router    *router
+		server    *http.Server
+		redirect  *http.Server
+		health    *health
+		lifecycle *lifecycle
 	}
 
 	context struct {
+		config     *Config
+		handler    *handler
 		request    request
 		response   response
 		parameters map[string]string
+		values     map[string]interface{}
 	}
 
 	Fresh interface {
@@ -33,12 +37,24 @@ type (
 		Start() error
 		Config() *Config
 		Group(string) Group
+		HealthCheck(string, func(httpContext.Context) error)
+		OnStart(func() error)
+		OnShutdown(func(httpContext.Context) error)
+		OnStop(func() error)
+		StartAsync() error
+		Serve(net.Listener) error
+		Addr() net.Addr
+		Handler() http.Handler
 	}
 
 	Context interface {
 		Request() Request
 		Response() Response
+		Get(string) interface{}
+		Set(string, interface{})
+		Invalidate(...string)
 		Writer(http.ResponseWriter)
+		Logger() Logger
 	}
 
 	HandlerFunc func(Context) error
@@ -52,6 +68,8 @@ func New() Fresh {
 	fresh.config.init(&fresh)
 	// Server setting
 	fresh.server = new(http.Server)
+	fresh.health = new(health)
+	fresh.lifecycle = newLifecycle()
 	// Fresh router
 	fresh.router = &router{&fresh, &route{}, make(map[string]string)}
 
@@ -63,50 +81,131 @@ func New() Fresh {
 	return &fresh
 }
 
-// Shutdown server
-func (f *fresh) Stop() error {
-	ctx, cancel := httpContext.WithTimeout(httpContext.Background(), 5*time.Second)
-	f.server.Shutdown(ctx)
-	cancel()
-	f.config.log("Server shutdown")
-	return nil
-}
-
-// Start HTTP server
+// Start HTTP server, block until a signal is received or the server is stopped
 func (f *fresh) Start() error {
-	shutdown := make(chan os.Signal)
-	port := strconv.Itoa(f.config.Port)
-	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
-	listener, err := net.Listen("tcp", f.config.Host+":"+port)
+	var shutdown chan os.Signal
+	if f.config.Shutdown == nil || f.config.Shutdown.Signals {
+		shutdown = make(chan os.Signal, 1)
+		signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(shutdown)
+	}
+	listener, err := f.listen()
 	if err != nil {
-		log.Fatal(err)
 		return err
 	}
+	serve := make(chan error, 1)
+	go func() {
+		serve <- f.Serve(listener)
+	}()
+	select {
+	case <-shutdown:
+		return f.Stop()
+	case err := <-serve:
+		if err != nil {
+			return err
+		}
+		// stopped by a Stop call, wait for the drain
+		<-f.lifecycle.done
+		return nil
+	}
+}
 
+// StartAsync listen and serve in background, listen errors are returned and serve errors logged
+func (f *fresh) StartAsync() error {
+	listener, err := f.listen()
+	if err != nil {
+		return err
+	}
+	serve := make(chan error, 1)
 	go func() {
-		f.config.banner()
-		f.config.log("Server listen on", f.config.Host+":"+port)
-		f.server.Handler = f.router
-		// default route
-		if f.router.route.children == nil {
-			f.GET("/", func(c Context) error {
-				return c.Response().Raw(http.StatusOK, welcome)
-			})
+		serve <- f.Serve(listener)
+	}()
+	// wait for the start hooks, serve errors come after
+	select {
+	case err := <-serve:
+		return err
+	case <-f.lifecycle.serving:
+	}
+	go func() {
+		if err := <-serve; err != nil {
+			f.config.logger().Error("server", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Serve accept connections on a listener until the server is stopped
+func (f *fresh) Serve(listener net.Listener) error {
+	f.config.banner()
+	f.server.Handler = f.router
+	f.config.configure(f.server)
+	listener = f.config.listener(listener)
+	// default route
+	if f.router.route.children == nil {
+		f.GET("/", func(c Context) error {
+			return c.Response().Raw(http.StatusOK, welcome)
+		})
+	}
+	if f.config.Router != nil && f.config.Router.Print {
+		PrintRouter(f.router)
+	}
+	// check for tsl before serve
+	if f.config.TSL != nil {
+		config, err := f.config.tsl()
+		if err != nil {
+			listener.Close()
+			return err
 		}
-		if f.config.Router != nil && f.config.Router.Print {
-			PrintRouter(f.router)
+		f.server.TLSConfig = config
+	}
+	if err := f.started(); err != nil {
+		listener.Close()
+		return err
+	}
+	if f.config.TSL != nil && f.config.TSL.Force {
+		if err := f.serveRedirect(listener.Addr()); err != nil {
+			listener.Close()
+			return err
 		}
-		// check for tsl before serve
-		if f.config.TSL != nil {
-			f.config.tsl()
+	}
+	f.lifecycle.listening(listener.Addr())
+	f.config.logger().Info("server listen", "addr", listener.Addr().String())
+	var err error
+	if f.config.TSL != nil {
+		err = f.server.ServeTLS(listener, "", "")
+	} else {
+		err = f.server.Serve(listener)
+	}
+	if err != http.ErrServerClosed {
+		// the redirect server doesn't outlive a failed server
+		f.lifecycle.Lock()
+		redirect := f.redirect
+		f.lifecycle.Unlock()
+		if redirect != nil {
+			redirect.Close()
 		}
-		f.server.Serve(listener)
-	}()
-	<-shutdown
-	f.Stop()
+		return err
+	}
 	return nil
 }
 
+// Listen on the config host and port
+func (f *fresh) listen() (net.Listener, error) {
+	return net.Listen("tcp", net.JoinHostPort(f.config.Host, strconv.Itoa(f.config.Port)))
+}
+
+// Addr return the address the server is bound to, nil before it starts
+func (f *fresh) Addr() net.Addr {
+	f.lifecycle.Lock()
+	defer f.lifecycle.Unlock()
+	return f.lifecycle.addr
+}
+
+// Handler return the router as a plain http handler
+func (f *fresh) Handler() http.Handler {
+	return f.router
+}
+
 // Config return server settings
 func (f *fresh) Config() *Config {
 	return f.config
@@ -138,9 +237,37 @@ func (c *context) Writer(w http.ResponseWriter) {
 	c.response.w = w
 }
 
+// Get a value stored in the context
+func (c *context) Get(k string) interface{} {
+	return c.values[k]
+}
+
+// Set a value in the context, available to the next handlers
+func (c *context) Set(k string, v interface{}) {
+	if c.values == nil {
+		c.values = make(map[string]interface{})
+	}
+	c.values[k] = v
+}
+
+// Invalidate remove the cached responses with the given tags
+func (c *context) Invalidate(tags ...string) {
+	if c.config.Cache != nil {
+		c.config.Cache.store().Invalidate(tags...)
+	}
+}
+
+// Logger return the config logger with the request id field if any
+func (c *context) Logger() Logger {
+	if id, ok := c.Get(ContextRequestID).(string); ok {
+		return c.config.logger().With("request_id", id)
+	}
+	return c.config.logger()
+}
+
 // Init set context request and response
 func (c *context) init(r *http.Request, w http.ResponseWriter) {
-	c.response = response{w: w, r: r}
-	c.request = request{r: r}
+	c.response = response{context: c, w: w, r: r}
+	c.request = request{context: c, r: r}
 	c.request.setRouteParam(c.parameters)
 }
@@ -4,10 +4,13 @@ import (
 	"encoding/json"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 type testRoute struct {
@@ -63,8 +66,10 @@ var req = []testRoute{
 
 func setup() fresh {
 	f := fresh{
-		config: &Config{},
-		server: new(http.Server),
+		config:    &Config{},
+		server:    new(http.Server),
+		health:    new(health),
+		lifecycle: newLifecycle(),
 	}
 	f.config.fresh = &f
 	f.config.init(&f)
@@ -126,6 +131,43 @@ func records(method string, body io.Reader, f fresh, t *testing.T) {
 
 func TestFresh_Run(t *testing.T) {}
 
+func TestFresh_StartAsync(t *testing.T) {
+	f := setup()
+	f.config.Host, f.config.Port, f.config.Banner = "127.0.0.1", 0, false
+	f.GET("/ping", func(c Context) error {
+		return c.Response().Raw(http.StatusOK, "pong")
+	})
+	if f.Addr() != nil {
+		t.Fatal("Expected no address before start")
+	}
+	if err := f.StartAsync(); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get("http://" + f.Addr().String() + "/ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "pong" {
+		t.Fatal("Unexpected body", string(body))
+	}
+	// the bound port is busy, start return the listen error
+	busy := setup()
+	host, port, _ := net.SplitHostPort(f.Addr().String())
+	busy.config.Host, busy.config.Banner = host, false
+	busy.config.Port, _ = strconv.Atoi(port)
+	if err := busy.Start(); err == nil {
+		t.Fatal("Expected a listen error")
+	}
+	if err := f.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := http.Get("http://" + f.Addr().String() + "/ping"); err == nil {
+		t.Fatal("Expected the server to be stopped")
+	}
+}
+
 func TestFresh_GET(t *testing.T) {
 	f := setup()
 	requests("GET", &f)
@@ -167,3 +209,161 @@ func TestFresh_OPTIONS(t *testing.T) {
 	requests("OPTIONS", &f)
 	records("OPTIONS", nil, f, t)
 }
+
+func TestResponse_Negotiate(t *testing.T) {
+	f := setup()
+	f.config.Encoder("text/csv", func(i interface{}) ([]byte, error) {
+		return []byte("field\n1"), nil
+	})
+	f.GET("/negotiate", func(c Context) error {
+		return c.Response().Negotiate(http.StatusOK, dataResponse{Field: 1})
+	})
+	tests := []struct {
+		accept string
+		code   int
+		ctype  string
+	}{
+		{"", http.StatusOK, MIMEAppJSON},
+		{"*/*", http.StatusOK, MIMEAppJSON},
+		{"text/html;q=0.5, application/xml", http.StatusOK, MIMEAppXML},
+		{"text/*;q=0.8, application/json;q=0.2", http.StatusOK, MIMETextXML},
+		{"text/csv", http.StatusOK, "text/csv"},
+		{"*/*, application/json;q=0", http.StatusOK, MIMEAppXML},
+		{"application/*;q=0, */*", http.StatusOK, MIMETextXML},
+		{"text/*;q=0, text/html, */*;q=0.1", http.StatusOK, MIMETextHTML},
+		{"image/png", http.StatusNotAcceptable, ""},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/negotiate", nil)
+		req.Header.Set(Accept, test.accept)
+		f.router.ServeHTTP(rec, req)
+		if rec.Code != test.code {
+			t.Fatal("Accept", test.accept, "returned", rec.Code, "instead of", test.code)
+		}
+		if test.ctype != "" && rec.Header().Get(ContentType) != test.ctype {
+			t.Fatal("Accept", test.accept, "returned", rec.Header().Get(ContentType), "instead of", test.ctype)
+		}
+		if rec.Header().Get(Vary) != Accept {
+			t.Fatal("Expected Vary header", Accept, "instead", rec.Header().Get(Vary))
+		}
+	}
+	// negotiated html is escaped
+	f.GET("/html", func(c Context) error {
+		return c.Response().Negotiate(http.StatusOK, "<script>alert(1)</script>")
+	})
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/html", nil)
+	req.Header.Set(Accept, MIMETextHTML)
+	f.router.ServeHTTP(rec, req)
+	if rec.Body.String() != "&lt;script&gt;alert(1)&lt;/script&gt;" {
+		t.Fatal("Expected escaped html instead of", rec.Body.String())
+	}
+}
+
+func TestRequest_Bind(t *testing.T) {
+	f := setup()
+	f.POST("/bind", func(c Context) error {
+		var data dataResponse
+		if err := c.Request().Bind(&data); err != nil {
+			return err
+		}
+		return c.Response().CSV(http.StatusOK, [][]string{{"field"}, {strconv.Itoa(data.Field)}})
+	})
+	tests := []struct {
+		ctype string
+		body  string
+		code  int
+	}{
+		{MIMEAppJSON, `{"field":3}`, http.StatusOK},
+		{"application/x-yaml", "field: 3", http.StatusOK},
+		{"application/xml", "<dataResponse><Field>3</Field></dataResponse>", http.StatusOK},
+		{"image/png", "", http.StatusUnsupportedMediaType},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/bind", strings.NewReader(test.body))
+		req.Header.Set(ContentType, test.ctype)
+		f.router.ServeHTTP(rec, req)
+		if rec.Code != test.code {
+			t.Fatal("Content-Type", test.ctype, "returned", rec.Code, "instead of", test.code)
+		}
+		if test.code == http.StatusOK && rec.Body.String() != "field\n3\n" {
+			t.Fatal("Expected csv body instead", rec.Body.String())
+		}
+	}
+}
+
+func TestResponse_Conditional(t *testing.T) {
+	f := setup()
+	f.GET("/conditional", func(c Context) error {
+		return c.Response().JSON(http.StatusOK, dataResponse{Field: 1})
+	})
+	f.PUT("/conditional", func(c Context) error {
+		if err := c.Response().Precondition("v2", time.Time{}); err != nil {
+			return err
+		}
+		return c.Response().Code(http.StatusNoContent)
+	})
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/conditional", nil)
+	f.router.ServeHTTP(rec, req)
+	tag := rec.Header().Get(ETag)
+	if !strings.HasPrefix(tag, "W/\"") {
+		t.Fatal("Expected a weak etag instead", tag)
+	}
+	rec = httptest.NewRecorder()
+	req.Header.Set(IfNoneMatch, tag)
+	f.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatal("Expected", http.StatusNotModified, "instead", rec.Code)
+	}
+	for match, code := range map[string]int{`"v1"`: http.StatusPreconditionFailed, `"v1", "v2"`: http.StatusNoContent, "*": http.StatusNoContent} {
+		rec = httptest.NewRecorder()
+		req, _ = http.NewRequest("PUT", "/conditional", nil)
+		req.Header.Set(IfMatch, match)
+		f.router.ServeHTTP(rec, req)
+		if rec.Code != code {
+			t.Fatal("If-Match", match, "returned", rec.Code, "instead of", code)
+		}
+	}
+}
+
+func TestRequest_Limit(t *testing.T) {
+	f := setup()
+	f.config.Limit = &Limit{Body: "8B"}
+	f.POST("/limit", func(c Context) error {
+		var data dataResponse
+		if err := c.Request().JSON(&data); err != nil {
+			return err
+		}
+		return c.Response().Code(http.StatusOK)
+	})
+	f.POST("/override", func(c Context) error {
+		var data dataResponse
+		if err := c.Request().JSON(&data); err != nil {
+			return err
+		}
+		return c.Response().Code(http.StatusOK)
+	}).Limit("1K")
+	tests := []struct {
+		path    string
+		chunked bool
+		code    int
+	}{
+		{"/limit", false, http.StatusRequestEntityTooLarge},
+		{"/limit", true, http.StatusRequestEntityTooLarge},
+		{"/override", false, http.StatusOK},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", test.path, strings.NewReader(`{"field":1,"fields":[]}`))
+		if test.chunked {
+			req.ContentLength = -1
+		}
+		f.router.ServeHTTP(rec, req)
+		if rec.Code != test.code {
+			t.Fatal("Path", test.path, "returned", rec.Code, "instead of", test.code)
+		}
+	}
+}
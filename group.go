@@ -8,64 +8,72 @@ type (
 	Group interface {
 		Rest
 		Group(string) Group
+		CORS(*CORS) Group
+		Require(...string) Group
+		Firewall(*Firewall) Group
+		RateLimit(*RateLimit) Group
 		After(...HandlerFunc) Group
 		Before(...HandlerFunc) Group
 	}
 
 	group struct {
-		parent *fresh
-		route  *route
+		parent    *fresh
+		route     *route
+		cors      *CORS
+		require   []string
+		firewall  *Firewall
+		ratelimit *RateLimit
 	}
 )
 
 // Group registration
 func (g *group) Group(path string) Group {
-	return g.parent.Group(filepath.Join(g.route.path, path)).After(g.route.after...).Before(g.route.before...)
+	return g.parent.Group(filepath.Join(g.route.path, path)).After(g.route.after...).Before(g.route.before...).CORS(g.cors).Require(g.require...).Firewall(g.firewall).RateLimit(g.ratelimit)
 }
 
 // WS api registration
 func (g *group) WS(path string, handler HandlerFunc) Handler {
-	return g.parent.WS(filepath.Join(g.route.path, path), handler).After(g.route.after...).Before(g.route.before...)
+	return g.apply(g.parent.WS(filepath.Join(g.route.path, path), handler))
 }
 
 // Register a resource (get, post, put, delete)
 func (g *group) CRUD(path string, h ...HandlerFunc) Resource {
-	return g.parent.CRUD(filepath.Join(g.route.path, path), h...).After(g.route.after...).Before(g.route.before...)
+	return g.parent.CRUD(filepath.Join(g.route.path, path), h...).After(g.route.after...).Before(g.route.before...).CORS(g.cors).Require(g.require...).Firewall(g.firewall).RateLimit(g.ratelimit)
 }
 
 // GET api registration
 func (g *group) GET(path string, handler HandlerFunc) Handler {
-	return g.parent.GET(filepath.Join(g.route.path, path), handler).After(g.route.after...).Before(g.route.before...)
+	return g.apply(g.parent.GET(filepath.Join(g.route.path, path), handler))
 }
 
 // PUT api registration
 func (g *group) PUT(path string, handler HandlerFunc) Handler {
-	return g.parent.PUT(filepath.Join(g.route.path, path), handler).After(g.route.after...).Before(g.route.before...)
+	return g.apply(g.parent.PUT(filepath.Join(g.route.path, path), handler))
 }
 
 // POST api registration
 func (g *group) POST(path string, handler HandlerFunc) Handler {
-	return g.parent.POST(filepath.Join(g.route.path, path), handler).After(g.route.after...).Before(g.route.before...)
+	return g.apply(g.parent.POST(filepath.Join(g.route.path, path), handler))
 }
 
 // TRACE api registration
 func (g *group) TRACE(path string, handler HandlerFunc) Handler {
-	return g.parent.TRACE(filepath.Join(g.route.path, path), handler).After(g.route.after...).Before(g.route.before...)
+	return g.apply(g.parent.TRACE(filepath.Join(g.route.path, path), handler))
 }
 
 // PATCH api registration
 func (g *group) PATCH(path string, handler HandlerFunc) Handler {
-	return g.parent.PATCH(filepath.Join(g.route.path, path), handler).After(g.route.after...).Before(g.route.before...)
+	return g.apply(g.parent.PATCH(filepath.Join(g.route.path, path), handler))
 }
 
 // DELETE api registration
 func (g *group) DELETE(path string, handler HandlerFunc) Handler {
-	return g.parent.DELETE(filepath.Join(g.route.path, path), handler).After(g.route.after...).Before(g.route.before...)
+	return g.apply(g.parent.DELETE(filepath.Join(g.route.path, path), handler))
 }
 
 // OPTIONS api registration
 func (g *group) OPTIONS(path string, handler HandlerFunc) Handler {
-	return g.parent.OPTIONS(filepath.Join(g.route.path, path), handler).After(g.route.after...).Before(g.route.before...)
+	return g.apply(g.parent.OPTIONS(filepath.Join(g.route.path, path), handler))
 }
 
 // ASSETS serve a list of static files. Array of files or directories TODO write logic
@@ -73,6 +81,41 @@ func (g *group) STATIC(static map[string]string) {
 	g.parent.STATIC(static)
 }
 
+// CORS policy for all the group routes, overrides the config one
+func (g *group) CORS(policy *CORS) Group {
+	if policy != nil {
+		g.cors = policy
+	}
+	return g
+}
+
+// Require roles or permissions for all the group routes
+func (g *group) Require(permissions ...string) Group {
+	g.require = append(g.require, permissions...)
+	return g
+}
+
+// Firewall for all the group routes, reload it to change the lists at runtime
+func (g *group) Firewall(fw *Firewall) Group {
+	if fw != nil {
+		g.firewall = fw
+	}
+	return g
+}
+
+// RateLimit all the group routes, the quota is shared when the limit has a name
+func (g *group) RateLimit(limit *RateLimit) Group {
+	if limit != nil {
+		g.ratelimit = limit
+	}
+	return g
+}
+
+// Apply the group settings to a registered route
+func (g *group) apply(h Handler) Handler {
+	return h.After(g.route.after...).Before(g.route.before...).CORS(g.cors).Require(g.require...).Firewall(g.firewall).RateLimit(g.ratelimit)
+}
+
 // After middleware
 func (g *group) After(middleware ...HandlerFunc) Group {
 	g.route.after = append(g.route.after, middleware...)
@@ -0,0 +1,148 @@
+package fresh
+
+import (
+	httpContext "context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// Health config, probes paths and checks timeout
+	Health struct {
+		Health  string        `yaml:"health,omitempty"`  // aggregated checks, default /healthz
+		Ready   string        `yaml:"ready,omitempty"`   // readiness, failing once the server stops, default /readyz
+		Live    string        `yaml:"live,omitempty"`    // liveness, default /livez
+		Timeout time.Duration `yaml:"timeout,omitempty"` // checks timeout, default 5s
+		Delay   time.Duration `yaml:"delay,omitempty"`   // readiness fails for delay before the server shutdown
+	}
+
+	// Registered health checks and shutdown state
+	health struct {
+		sync.RWMutex
+		names    []string
+		checks   map[string]func(httpContext.Context) error
+		stopping int32
+	}
+
+	// Health status of a check
+	healthStatus struct {
+		Status string `json:"status"`
+		Error  string `json:"error,omitempty"`
+	}
+
+	// Aggregated health status
+	healthReport struct {
+		Status string                  `json:"status"`
+		Checks map[string]healthStatus `json:"checks,omitempty"`
+	}
+)
+
+// HealthCheck register a check run by the health and readiness probes
+func (f *fresh) HealthCheck(name string, check func(httpContext.Context) error) {
+	f.health.Lock()
+	defer f.health.Unlock()
+	if f.health.checks == nil {
+		f.health.checks = make(map[string]func(httpContext.Context) error)
+	}
+	if _, ok := f.health.checks[name]; !ok {
+		f.health.names = append(f.health.names, name)
+	}
+	f.health.checks[name] = check
+}
+
+// Run all the checks concurrently
+func (h *health) run(ctx httpContext.Context, timeout time.Duration) healthReport {
+	h.RLock()
+	names := append([]string(nil), h.names...)
+	checks := make([]func(httpContext.Context) error, len(names))
+	for i, name := range names {
+		checks[i] = h.checks[name]
+	}
+	h.RUnlock()
+	report := healthReport{Status: "ok", Checks: make(map[string]healthStatus, len(names))}
+	if len(names) == 0 {
+		return report
+	}
+	ctx, cancel := httpContext.WithTimeout(ctx, timeout)
+	defer cancel()
+	results := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i := range checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			done := make(chan error, 1)
+			go func() {
+				done <- checks[i](ctx)
+			}()
+			select {
+			case results[i] = <-done:
+			case <-ctx.Done():
+				results[i] = ctx.Err()
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i, name := range names {
+		if results[i] != nil {
+			report.Status = "fail"
+			report.Checks[name] = healthStatus{Status: "fail", Error: results[i].Error()}
+		} else {
+			report.Checks[name] = healthStatus{Status: "ok"}
+		}
+	}
+	return report
+}
+
+// Paths return the probes paths with their defaults
+func (h *Health) paths() (health, ready, live string) {
+	health, ready, live = h.Health, h.Ready, h.Live
+	if health == "" {
+		health = "/healthz"
+	}
+	if ready == "" {
+		ready = "/readyz"
+	}
+	if live == "" {
+		live = "/livez"
+	}
+	return
+}
+
+// Health return the route serving a probe, nil when the request is for another path
+func (r *router) health(request *http.Request) *handler {
+	h := r.config.Health
+	if h == nil || (request.Method != "GET" && request.Method != "HEAD") {
+		return nil
+	}
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	health, ready, live := h.paths()
+	var probe HandlerFunc
+	switch request.URL.Path {
+	case live:
+		probe = func(c Context) error {
+			return c.Response().JSON(http.StatusOK, healthReport{Status: "ok"})
+		}
+	case ready, health:
+		readiness := request.URL.Path == ready
+		probe = func(c Context) error {
+			if readiness && atomic.LoadInt32(&r.fresh.health.stopping) == 1 {
+				return c.Response().JSON(http.StatusServiceUnavailable, healthReport{Status: "stopping"})
+			}
+			report := r.fresh.health.run(c.Request().Get().Context(), timeout)
+			code := http.StatusOK
+			if report.Status != "ok" {
+				code = http.StatusServiceUnavailable
+			}
+			return c.Response().JSON(code, report)
+		}
+	default:
+		return nil
+	}
+	return &handler{route: &route{path: request.URL.Path}, method: request.Method, ctrl: probe}
+}
@@ -0,0 +1,78 @@
+package fresh
+
+import (
+	httpContext "context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func probe(f fresh, path string) (int, healthReport) {
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	f.router.ServeHTTP(rec, req)
+	var report healthReport
+	json.Unmarshal(rec.Body.Bytes(), &report)
+	return rec.Code, report
+}
+
+func TestHealth(t *testing.T) {
+	f := setup()
+	f.config.Health = &Health{Ready: "/ready"}
+	failing := errors.New("connection refused")
+	f.HealthCheck("db", func(ctx httpContext.Context) error {
+		return nil
+	})
+	f.HealthCheck("cache", func(ctx httpContext.Context) error {
+		return failing
+	})
+	if code, report := probe(f, "/healthz"); code != http.StatusServiceUnavailable || report.Status != "fail" || report.Checks["db"].Status != "ok" || report.Checks["cache"].Error != failing.Error() {
+		t.Fatal("Unexpected health report", code, report)
+	}
+	failing = nil
+	f.HealthCheck("cache", func(ctx httpContext.Context) error {
+		return nil
+	})
+	if code, report := probe(f, "/ready"); code != http.StatusOK || report.Status != "ok" || len(report.Checks) != 2 {
+		t.Fatal("Unexpected readiness report", code, report)
+	}
+	f.Stop()
+	if code, report := probe(f, "/ready"); code != http.StatusServiceUnavailable || report.Status != "stopping" {
+		t.Fatal("Expected readiness to fail once stopping", code, report)
+	}
+	if code, _ := probe(f, "/livez"); code != http.StatusOK {
+		t.Fatal("Expected liveness to succeed while stopping", code)
+	}
+}
+
+func TestHealth_Delay(t *testing.T) {
+	f := setup()
+	f.GET("/work", func(c Context) error {
+		return c.Response().Code(http.StatusOK)
+	})
+	if code, _ := probe(f, "/readyz"); code != http.StatusNotFound {
+		t.Fatal("Expected the probes to be disabled by default", code)
+	}
+	f.config.Health = &Health{Delay: 200 * time.Millisecond}
+	stopped := make(chan struct{})
+	go func() {
+		f.Stop()
+		close(stopped)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if code, _ := probe(f, "/readyz"); code != http.StatusServiceUnavailable {
+		t.Fatal("Expected readiness to fail during the delay", code)
+	}
+	if code, _ := probe(f, "/work"); code != http.StatusOK {
+		t.Fatal("Expected the requests to be served during the delay", code)
+	}
+	select {
+	case <-stopped:
+		t.Fatal("Expected the shutdown to wait for the delay")
+	default:
+	}
+	<-stopped
+}
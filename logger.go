@@ -0,0 +1,164 @@
+package fresh
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Logger is a leveled logger with structured fields, given as alternated keys and values
+	Logger interface {
+		Debug(string, ...interface{})
+		Info(string, ...interface{})
+		Warn(string, ...interface{})
+		Error(string, ...interface{})
+		With(...interface{}) Logger
+	}
+
+	// Default logger, console or json format
+	logger struct {
+		mu     *sync.Mutex
+		out    io.Writer
+		level  int
+		json   bool
+		fields []interface{}
+	}
+)
+
+// Log levels
+const (
+	LevelDebug = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levels = []string{"debug", "info", "warn", "error"}
+
+// NewLogger return a logger that write on out, level is debug, info, warn or error and format console or json
+func NewLogger(out io.Writer, level, format string) Logger {
+	l := &logger{mu: new(sync.Mutex), out: out, level: LevelInfo, json: strings.EqualFold(format, "json")}
+	for i, name := range levels {
+		if strings.EqualFold(level, name) {
+			l.level = i
+		}
+	}
+	return l
+}
+
+// Debug message
+func (l *logger) Debug(msg string, kv ...interface{}) {
+	l.log(LevelDebug, msg, kv)
+}
+
+// Info message
+func (l *logger) Info(msg string, kv ...interface{}) {
+	l.log(LevelInfo, msg, kv)
+}
+
+// Warn message
+func (l *logger) Warn(msg string, kv ...interface{}) {
+	l.log(LevelWarn, msg, kv)
+}
+
+// Error message
+func (l *logger) Error(msg string, kv ...interface{}) {
+	l.log(LevelError, msg, kv)
+}
+
+// With return a logger that add the given fields to every message
+func (l *logger) With(kv ...interface{}) Logger {
+	child := *l
+	child.fields = append(append([]interface{}(nil), l.fields...), kv...)
+	return &child
+}
+
+// Write a message if its level is enabled
+func (l *logger) log(level int, msg string, kv []interface{}) {
+	if level < l.level {
+		return
+	}
+	kv = append(append([]interface{}(nil), l.fields...), kv...)
+	if len(kv)%2 != 0 {
+		kv = append(kv, nil)
+	}
+	now := time.Now().Format(time.RFC3339)
+	var line []byte
+	if l.json {
+		entry := map[string]interface{}{"time": now, "level": levels[level], "msg": msg}
+		for i := 0; i < len(kv); i += 2 {
+			v := kv[i+1]
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+			entry[fmt.Sprint(kv[i])] = v
+		}
+		b, err := json.Marshal(entry)
+		if err != nil {
+			b, _ = json.Marshal(map[string]interface{}{"time": now, "level": levels[level], "msg": msg, "error": err.Error()})
+		}
+		line = append(b, '\n')
+	} else {
+		var b strings.Builder
+		b.WriteString(now + " " + strings.ToUpper(levels[level]) + " " + msg)
+		for i := 0; i < len(kv); i += 2 {
+			v := fmt.Sprint(kv[i+1])
+			if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+				v = strconv.Quote(v)
+			}
+			b.WriteString(" " + fmt.Sprint(kv[i]) + "=" + v)
+		}
+		line = []byte(b.String() + "\n")
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(line)
+}
+
+// Logger return the config logger, the default one is built from the logs config on first use
+func (c *Config) logger() Logger {
+	c.logOnce.Do(func() {
+		if c.Logger != nil {
+			return
+		}
+		var out []io.Writer
+		if c.Logs.Stdout {
+			out = append(out, os.Stdout)
+		}
+		if c.Logs.File {
+			path := c.Logs.Path
+			if path == "" {
+				path = logs
+			}
+			f, err := newRotateWriter(path, c.Logs)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "fresh: logs file:", err)
+			} else {
+				c.logFile = f
+				out = append(out, f)
+			}
+		}
+		c.Logger = NewLogger(io.MultiWriter(out...), c.Logs.Level, c.Logs.Format)
+	})
+	return c.Logger
+}
+
+// CloseLogs close the logs and the access log files, their SIGHUP handling included
+func (c *Config) closeLogs() error {
+	var err error
+	if c.AccessLog != nil {
+		err = c.AccessLog.close()
+	}
+	if c.logFile != nil {
+		if cerr := c.logFile.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,41 @@
+package fresh
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := NewLogger(buf, "warn", "console")
+	l.Info("skipped")
+	l.With("ip", "10.0.0.1").Warn("firewall rejected", "uri", "/a b")
+	if line := buf.String(); strings.Contains(line, "skipped") || !strings.Contains(line, `WARN firewall rejected ip=10.0.0.1 uri="/a b"`) {
+		t.Fatal("Unexpected console output", line)
+	}
+}
+
+func TestLogger_JSON(t *testing.T) {
+	buf := new(bytes.Buffer)
+	f := setup()
+	f.config.RequestID = &RequestID{}
+	f.config.Logger = NewLogger(buf, "info", "json")
+	f.GET("/logged", func(c Context) error {
+		return c.Response().Code(http.StatusCreated)
+	})
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/logged", nil)
+	req.Header.Set(XRequestID, "abc-123")
+	f.router.ServeHTTP(rec, req)
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err, buf.String())
+	}
+	if entry["level"] != "info" || entry["msg"] != "request" || entry["request_id"] != "abc-123" || entry["uri"] != "/logged" || entry["status"] != float64(http.StatusCreated) {
+		t.Fatal("Unexpected json entry", buf.String())
+	}
+}
@@ -0,0 +1,189 @@
+package fresh
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Metrics config, requests and websockets are exposed in the Prometheus text format on path
+	Metrics struct {
+		once      sync.Once
+		mu        sync.Mutex
+		requests  map[[3]string]*histogram
+		inflight  map[string]int64
+		ws        map[string]int64
+		wsTotal   map[string]uint64
+		Path      string    `yaml:"path,omitempty"`      // admin path, default /metrics
+		Namespace string    `yaml:"namespace,omitempty"` // metric names prefix, default fresh
+		Buckets   []float64 `yaml:"buckets,omitempty"`   // latency buckets in seconds
+		Firewall  *Firewall `yaml:"firewall,omitempty"`  // addresses allowed to read the metrics
+	}
+
+	// Latency histogram of a method, route and status
+	histogram struct {
+		count   uint64
+		sum     float64
+		buckets []uint64
+	}
+)
+
+// Prometheus default latency buckets
+var defaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Init the metrics maps and the default settings
+func (m *Metrics) init() {
+	m.once.Do(func() {
+		m.requests = make(map[[3]string]*histogram)
+		m.inflight = make(map[string]int64)
+		m.ws = make(map[string]int64)
+		m.wsTotal = make(map[string]uint64)
+		if m.Path == "" {
+			m.Path = "/metrics"
+		}
+		if m.Namespace == "" {
+			m.Namespace = "fresh"
+		}
+		if len(m.Buckets) == 0 {
+			m.Buckets = defaultBuckets
+		}
+		sort.Float64s(m.Buckets)
+	})
+}
+
+// MethodLabel return a standard method or OTHER, unknown methods are collapsed to bound the series
+func methodLabel(m string) string {
+	switch m {
+	case "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "CONNECT", "TRACE":
+		return m
+	}
+	return "OTHER"
+}
+
+// Begin count a request in flight
+func (m *Metrics) begin(method string) {
+	m.init()
+	m.mu.Lock()
+	m.inflight[methodLabel(method)]++
+	m.mu.Unlock()
+}
+
+// Observe a served request, labeled by route pattern to bound the series
+func (m *Metrics) observe(c *context, w *accessWriter, start time.Time) {
+	route := "unmatched"
+	if c.handler != nil && c.handler.route != nil {
+		route = c.handler.route.pattern()
+	}
+	method := methodLabel(c.request.r.Method)
+	seconds := time.Since(start).Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inflight[method]--
+	key := [3]string{method, route, strconv.Itoa(w.status())}
+	h, ok := m.requests[key]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(m.Buckets))}
+		m.requests[key] = h
+	}
+	h.count++
+	h.sum += seconds
+	for i, b := range m.Buckets {
+		if seconds <= b {
+			h.buckets[i]++
+		}
+	}
+}
+
+// Connected update the websocket gauge of a route
+func (m *Metrics) connected(route string, delta int64) {
+	m.init()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ws[route] += delta
+	if delta > 0 {
+		m.wsTotal[route]++
+	}
+}
+
+// Serve write the metrics in the Prometheus text exposition format
+func (m *Metrics) serve(c Context) error {
+	m.init()
+	var b strings.Builder
+	m.mu.Lock()
+	name := m.Namespace + "_http_requests_total"
+	fmt.Fprintf(&b, "# HELP %s Total number of HTTP requests.\n# TYPE %s counter\n", name, name)
+	keys := make([][3]string, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return strings.Join(keys[i][:], " ") < strings.Join(keys[j][:], " ")
+	})
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s{%s} %d\n", name, labels("method", k[0], "route", k[1], "status", k[2]), m.requests[k].count)
+	}
+	name = m.Namespace + "_http_request_duration_seconds"
+	fmt.Fprintf(&b, "# HELP %s HTTP request latency in seconds.\n# TYPE %s histogram\n", name, name)
+	for _, k := range keys {
+		h := m.requests[k]
+		l := labels("method", k[0], "route", k[1], "status", k[2])
+		for i, bound := range m.Buckets {
+			fmt.Fprintf(&b, "%s_bucket{%s,le=\"%s\"} %d\n", name, l, strconv.FormatFloat(bound, 'g', -1, 64), h.buckets[i])
+		}
+		fmt.Fprintf(&b, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, l, h.count)
+		fmt.Fprintf(&b, "%s_sum{%s} %s\n", name, l, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "%s_count{%s} %d\n", name, l, h.count)
+	}
+	name = m.Namespace + "_http_requests_in_flight"
+	fmt.Fprintf(&b, "# HELP %s HTTP requests being served.\n# TYPE %s gauge\n", name, name)
+	for _, k := range sortedKeys(m.inflight) {
+		fmt.Fprintf(&b, "%s{%s} %d\n", name, labels("method", k), m.inflight[k])
+	}
+	name = m.Namespace + "_websocket_connections"
+	fmt.Fprintf(&b, "# HELP %s Open websocket connections.\n# TYPE %s gauge\n", name, name)
+	for _, k := range sortedKeys(m.ws) {
+		fmt.Fprintf(&b, "%s{%s} %d\n", name, labels("route", k), m.ws[k])
+	}
+	name = m.Namespace + "_websocket_connections_total"
+	fmt.Fprintf(&b, "# HELP %s Total number of websocket connections.\n# TYPE %s counter\n", name, name)
+	for _, k := range sortedKeys(m.ws) {
+		fmt.Fprintf(&b, "%s{%s} %d\n", name, labels("route", k), m.wsTotal[k])
+	}
+	m.mu.Unlock()
+	c.Response().Type("text/plain; version=0.0.4; charset=utf-8")
+	return c.Response().Raw(http.StatusOK, b.String())
+}
+
+// Labels format label pairs with escaped values
+func labels(kv ...string) string {
+	pairs := make([]string, 0, len(kv)/2)
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	for i := 0; i+1 < len(kv); i += 2 {
+		pairs = append(pairs, kv[i]+`="`+replacer.Replace(kv[i+1])+`"`)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// SortedKeys return the keys of a gauge map in order
+func sortedKeys(m map[string]int64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Handler return the route serving the metrics, nil when the request is for another path
+func (m *Metrics) handler(request *http.Request) *handler {
+	m.init()
+	if request.URL.Path != m.Path || (request.Method != "GET" && request.Method != "HEAD") {
+		return nil
+	}
+	return &handler{route: &route{path: m.Path}, method: request.Method, firewall: m.Firewall, ctrl: m.serve}
+}
@@ -0,0 +1,51 @@
+package fresh
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	f := setup()
+	f.config.Metrics = &Metrics{Path: "/admin/metrics", Buckets: []float64{0.5, 1}, Firewall: &Firewall{Allow: []string{"127.0.0.1"}}}
+	f.GET("/items/:id", func(c Context) error {
+		return c.Response().Code(http.StatusOK)
+	})
+	for _, path := range []string{"/items/1", "/items/2", "/missing"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		f.router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	req, _ := http.NewRequest("FOO", "/items/1", nil)
+	f.router.ServeHTTP(httptest.NewRecorder(), req)
+	// only the allowed addresses read the metrics
+	rec := httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/admin/metrics", nil)
+	req.RemoteAddr = "203.0.113.9:1234"
+	f.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatal("Expected a forbidden metrics request, returned", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/admin/metrics", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	f.router.ServeHTTP(rec, req)
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE fresh_http_requests_total counter",
+		`fresh_http_requests_total{method="GET",route="/items/:id",status="200"} 2`,
+		`fresh_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`fresh_http_requests_total{method="OTHER",route="unmatched",status="404"} 1`,
+		`fresh_http_request_duration_seconds_bucket{method="GET",route="/items/:id",status="200",le="+Inf"} 2`,
+		`fresh_http_request_duration_seconds_count{method="GET",route="/items/:id",status="200"} 2`,
+		`fresh_http_requests_in_flight{method="GET"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatal("Expected", line, "in", body)
+		}
+	}
+	if !strings.HasPrefix(rec.Header().Get(ContentType), "text/plain; version=0.0.4") {
+		t.Fatal("Unexpected content type", rec.Header().Get(ContentType))
+	}
+}
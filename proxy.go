@@ -0,0 +1,211 @@
+package fresh
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Proxy config, forwarded headers are used only when sent by a trusted address
+type Proxy struct {
+	once    sync.Once
+	nets    []*net.IPNet
+	Trusted []string `yaml:"trusted,omitempty"`
+}
+
+// Trust check if an address belongs to a trusted CIDR, single addresses are allowed
+func (p *Proxy) trust(ip net.IP) bool {
+	if p == nil || ip == nil {
+		return false
+	}
+	p.once.Do(func() {
+		p.nets, _ = parseCIDR(p.Trusted)
+	})
+	for _, n := range p.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseCIDR parse a list of CIDR or addresses, invalid entries are skipped and the first error returned
+func parseCIDR(list []string) (nets []*net.IPNet, err error) {
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil {
+				bits := 128
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 32
+				}
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			} else if err == nil {
+				err = fmt.Errorf("invalid address %q", s)
+			}
+			continue
+		}
+		_, n, e := net.ParseCIDR(s)
+		if e != nil {
+			if err == nil {
+				err = e
+			}
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return
+}
+
+// RemoteIP return the address of the peer connection
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Forwarded parse a RFC 7239 header in a list of elements
+func forwarded(header string) (list []map[string]string) {
+	for _, element := range strings.Split(header, ",") {
+		pairs := make(map[string]string)
+		for _, pair := range strings.Split(element, ";") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) == 2 {
+				pairs[strings.ToLower(kv[0])] = strings.Trim(kv[1], `"`)
+			}
+		}
+		list = append(list, pairs)
+	}
+	return
+}
+
+// NodeIP return the address of a Forwarded for node, without port and brackets
+func nodeIP(node string) string {
+	if strings.HasPrefix(node, "[") {
+		if i := strings.Index(node, "]"); i > 0 {
+			return node[1:i]
+		}
+	}
+	if strings.Count(node, ":") == 1 {
+		return strings.Split(node, ":")[0]
+	}
+	return node
+}
+
+// Hops return the forwarded addresses and the distance from the nearest hop of the client one, trusted proxies are skipped
+func (req *request) hops() (hops []string, depth int) {
+	for _, element := range req.nodes() {
+		hops = append(hops, nodeIP(element["for"]))
+	}
+	// forwarded elements without a node fall back to X-Forwarded-For
+	if len(hops) == 0 {
+		for _, hop := range strings.Split(strings.Join(req.r.Header.Values(XForwardedFor), ","), ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(hops[i])
+		if ip == nil || !req.config.Proxy.trust(ip) {
+			return hops, len(hops) - 1 - i
+		}
+	}
+	// every hop is trusted, the first one is the client
+	if len(hops) > 0 {
+		depth = len(hops) - 1
+	}
+	return hops, depth
+}
+
+// Nodes return the Forwarded elements with a usable for node
+func (req *request) nodes() (nodes []map[string]string) {
+	h := req.r.Header.Get(Forwarded)
+	if h == "" {
+		return nil
+	}
+	for _, element := range forwarded(h) {
+		if node := nodeIP(element["for"]); node != "" && !strings.EqualFold(node, "unknown") {
+			nodes = append(nodes, element)
+		}
+	}
+	return nodes
+}
+
+// Element return the Forwarded element of the client, the nearest one when no element has a for node
+func (req *request) element(depth int) map[string]string {
+	if nodes := req.nodes(); len(nodes) > 0 {
+		return nodes[nearest(len(nodes), depth)]
+	}
+	elements := forwarded(req.r.Header.Get(Forwarded))
+	return elements[len(elements)-1]
+}
+
+// Nearest return the index of the element at a distance from the nearest hop, the first one when the list is shorter
+func nearest(n, depth int) int {
+	if i := n - 1 - depth; i > 0 {
+		return i
+	}
+	return 0
+}
+
+// RealIP return the client address, forwarded headers are walked from the nearest hop skipping trusted proxies
+func (req *request) RealIP() string {
+	remote := remoteIP(req.r)
+	if !req.config.Proxy.trust(net.ParseIP(remote)) {
+		return remote
+	}
+	if hops, depth := req.hops(); len(hops) > 0 {
+		return hops[nearest(len(hops), depth)]
+	}
+	if h := req.r.Header.Get(XRealIP); h != "" {
+		return strings.TrimSpace(h)
+	}
+	return remote
+}
+
+// Scheme return the request scheme, forwarded headers are used when sent by a trusted proxy
+func (req *request) Scheme() string {
+	if req.r.TLS != nil {
+		return "https"
+	}
+	if req.config.Proxy.trust(net.ParseIP(remoteIP(req.r))) {
+		_, depth := req.hops()
+		if req.r.Header.Get(Forwarded) != "" {
+			if proto := req.element(depth)["proto"]; proto != "" {
+				return strings.ToLower(proto)
+			}
+		}
+		for _, k := range []string{XForwardedProto, XForwardedProtocol, XUrlScheme} {
+			if v := strings.Join(req.r.Header.Values(k), ","); v != "" {
+				values := strings.Split(v, ",")
+				return strings.ToLower(strings.TrimSpace(values[nearest(len(values), depth)]))
+			}
+		}
+		if strings.EqualFold(req.r.Header.Get(XForwardedSsl), "on") {
+			return "https"
+		}
+	}
+	return "http"
+}
+
+// Host return the request host, forwarded headers are used when sent by a trusted proxy
+func (req *request) Host() string {
+	if req.config.Proxy.trust(net.ParseIP(remoteIP(req.r))) {
+		_, depth := req.hops()
+		if req.r.Header.Get(Forwarded) != "" {
+			if host := req.element(depth)["host"]; host != "" {
+				return host
+			}
+		}
+		if v := strings.Join(req.r.Header.Values(XForwardedHost), ","); v != "" {
+			values := strings.Split(v, ",")
+			return strings.TrimSpace(values[nearest(len(values), depth)])
+		}
+	}
+	return req.r.Host
+}
@@ -0,0 +1,51 @@
+package fresh
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequest_RealIP(t *testing.T) {
+	f := setup()
+	f.config.Proxy = &Proxy{Trusted: []string{"10.0.0.0/8", "192.168.1.1"}}
+	f.GET("/ip", func(c Context) error {
+		return c.Response().Raw(http.StatusOK, c.Request().RealIP()+" "+c.Request().Scheme()+" "+c.Request().Host())
+	})
+	tests := []struct {
+		remote   string
+		header   string
+		value    string
+		expected string
+	}{
+		{"203.0.113.9:80", XForwardedFor, "1.1.1.1", "203.0.113.9 http example.com"},
+		{"10.0.0.1:80", XForwardedFor, "6.6.6.6, 1.1.1.1, 10.0.0.2", "1.1.1.1 http example.com"},
+		{"192.168.1.1:80", Forwarded, `for=1.1.1.1;proto=https;host=api.example.com, for="[2001:db8::1]:80"`, "2001:db8::1 http example.com"},
+		{"10.0.0.1:80", Forwarded, `for=6.6.6.6;proto=http;host=evil.com, for=1.1.1.1;proto=https;host=api.example.com, for=10.0.0.2`, "1.1.1.1 https api.example.com"},
+		{"10.0.0.1:80", Forwarded, "proto=https;host=api.example.com", "10.0.0.1 https api.example.com"},
+		{"10.0.0.1:80", Forwarded, "for=unknown, for=1.1.1.1;proto=https", "1.1.1.1 https example.com"},
+		{"10.0.0.1:80", XRealIP, "1.1.1.1", "1.1.1.1 http example.com"},
+		{"10.0.0.1:80", XForwardedProto, "https", "10.0.0.1 https example.com"},
+		{"10.0.0.1:80", XForwardedHost, "evil.com, api.example.com", "10.0.0.1 http api.example.com"},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://example.com/ip", nil)
+		req.RemoteAddr = test.remote
+		req.Header.Set(test.header, test.value)
+		f.router.ServeHTTP(rec, req)
+		if rec.Body.String() != test.expected {
+			t.Fatal("Header", test.header, "returned", rec.Body.String(), "instead of", test.expected)
+		}
+	}
+	// a forwarded header without nodes fall back to X-Forwarded-For
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://example.com/ip", nil)
+	req.RemoteAddr = "10.0.0.1:80"
+	req.Header.Set(Forwarded, "proto=https")
+	req.Header.Set(XForwardedFor, "1.1.1.1")
+	f.router.ServeHTTP(rec, req)
+	if rec.Body.String() != "1.1.1.1 https example.com" {
+		t.Fatal("Expected the X-Forwarded-For client instead of", rec.Body.String())
+	}
+}
@@ -0,0 +1,217 @@
+package fresh
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// RateLimit config, limit requests are allowed every period for each key
+	RateLimit struct {
+		once      sync.Once
+		Name      string               `yaml:"name,omitempty"`
+		Limit     int                  `yaml:"limit,omitempty"`
+		Burst     int                  `yaml:"burst,omitempty"`
+		Period    time.Duration        `yaml:"period,omitempty"`
+		Algorithm string               `yaml:"algorithm,omitempty"`
+		Key       string               `yaml:"key,omitempty"`
+		KeyFunc   func(Context) string `yaml:"-"`
+		Store     LimiterStore         `yaml:"-"`
+		Filters   []Filter             `yaml:"-"`
+	}
+
+	// Quota left for a key after a request
+	Quota struct {
+		Allowed   bool
+		Limit     int
+		Remaining int
+		Reset     time.Duration
+	}
+
+	// LimiterStore take a request from the quota of a key, implement it for shared backends
+	LimiterStore interface {
+		Take(string, *RateLimit) (Quota, error)
+	}
+
+	// In-memory limiter store with expiry
+	limiterStore struct {
+		sync.Mutex
+		entries map[string]*limiterEntry
+		sweep   time.Time
+	}
+
+	limiterEntry struct {
+		tokens   float64
+		current  int
+		previous int
+		window   time.Time
+		last     time.Time
+		expires  time.Time
+	}
+)
+
+// Rate limit algorithms
+const (
+	TokenBucket   = "token-bucket"
+	SlidingWindow = "sliding-window"
+)
+
+// ErrTooManyRequests returned when a key exceed its quota
+var ErrTooManyRequests = errors.New(http.StatusText(http.StatusTooManyRequests))
+
+// NewLimiterStore return an in-memory limiter store, unused keys expire after a period
+func NewLimiterStore() LimiterStore {
+	return &limiterStore{entries: make(map[string]*limiterEntry)}
+}
+
+// Take a request from a key quota
+func (s *limiterStore) Take(key string, l *RateLimit) (Quota, error) {
+	s.Lock()
+	defer s.Unlock()
+	now := time.Now()
+	period, limit := l.period(), l.limit()
+	if now.After(s.sweep) {
+		for k, e := range s.entries {
+			if now.After(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.sweep = now.Add(time.Minute)
+	}
+	e, ok := s.entries[key]
+	if !ok {
+		e = &limiterEntry{tokens: float64(l.burst()), window: now.Truncate(period), last: now}
+		s.entries[key] = e
+	}
+	e.expires = now.Add(2 * period)
+	q := Quota{Limit: limit}
+	if l.Algorithm == SlidingWindow {
+		window := now.Truncate(period)
+		if window.Sub(e.window) >= 2*period {
+			e.previous, e.current = 0, 0
+		} else if window.After(e.window) {
+			e.previous, e.current = e.current, 0
+		}
+		e.window = window
+		elapsed := float64(now.Sub(window)) / float64(period)
+		count := float64(e.previous)*(1-elapsed) + float64(e.current)
+		q.Reset = window.Add(period).Sub(now)
+		if count+1 > float64(limit) {
+			return q, nil
+		}
+		e.current++
+		q.Allowed = true
+		q.Remaining = int(math.Max(0, float64(limit)-count-1))
+		return q, nil
+	}
+	// token bucket, refilled at limit tokens every period
+	rate := float64(limit) / float64(period)
+	e.tokens = math.Min(float64(l.burst()), e.tokens+float64(now.Sub(e.last))*rate)
+	e.last = now
+	if e.tokens < 1 {
+		q.Reset = time.Duration((1 - e.tokens) / rate)
+		return q, nil
+	}
+	e.tokens--
+	q.Allowed = true
+	q.Remaining = int(e.tokens)
+	q.Reset = time.Duration((float64(l.burst()) - e.tokens) / rate)
+	return q, nil
+}
+
+// Limit of requests every period, default 60
+func (l *RateLimit) limit() int {
+	if l.Limit > 0 {
+		return l.Limit
+	}
+	return 60
+}
+
+// Burst is the token bucket capacity, default the limit
+func (l *RateLimit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.limit()
+}
+
+// Period of the limit, default a minute
+func (l *RateLimit) period() time.Duration {
+	if l.Period > 0 {
+		return l.Period
+	}
+	return time.Minute
+}
+
+// Return the limiter store, create the in-memory one if missing
+func (l *RateLimit) store() LimiterStore {
+	l.once.Do(func() {
+		if l.Store == nil {
+			l.Store = NewLimiterStore()
+		}
+	})
+	return l.Store
+}
+
+// Client key, by ip, authenticated user, request header or custom func
+func (l *RateLimit) key(c Context) string {
+	if l.KeyFunc != nil {
+		return l.KeyFunc(c)
+	}
+	switch {
+	case l.Key == "user":
+		if user := principal(c); user != "" {
+			return user
+		}
+	case strings.HasPrefix(l.Key, "header:"):
+		if v := c.Request().Header().Get(strings.TrimPrefix(l.Key, "header:")); v != "" {
+			return v
+		}
+	}
+	return c.Request().RealIP()
+}
+
+// Take a request from the client quota and set the RateLimit headers
+func (l *RateLimit) take(c Context, scope string) error {
+	for _, f := range l.Filters {
+		if f(c) {
+			return nil
+		}
+	}
+	if l.Name != "" {
+		scope = l.Name
+	}
+	q, err := l.store().Take(scope+":"+l.key(c), l)
+	if err != nil {
+		return err
+	}
+	reset := strconv.Itoa(int(math.Ceil(q.Reset.Seconds())))
+	h := c.Response().Get().Header()
+	h.Set(RateLimitLimit, strconv.Itoa(q.Limit))
+	h.Set(RateLimitRemaining, strconv.Itoa(q.Remaining))
+	h.Set(RateLimitReset, reset)
+	h.Set(RateLimitPolicy, strconv.Itoa(q.Limit)+";w="+strconv.Itoa(int(l.period().Seconds())))
+	if !q.Allowed {
+		h.Set(RetryAfter, reset)
+		return c.Response().Error(http.StatusTooManyRequests, ErrTooManyRequests)
+	}
+	return nil
+}
+
+// Throttle a request with the global limit by user and the route rate limit, once the principal is set
+func (r *router) throttle(h *handler, c *context) error {
+	if l := r.config.RateLimit; l != nil && l.Key == "user" {
+		if err := l.take(c, "global"); err != nil {
+			return err
+		}
+	}
+	if h.ratelimit == nil {
+		return nil
+	}
+	return h.ratelimit.take(c, h.method+" "+h.route.pattern())
+}
@@ -0,0 +1,94 @@
+package fresh
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	for _, algorithm := range []string{TokenBucket, SlidingWindow} {
+		f := setup()
+		f.GET("/limited", func(c Context) error {
+			return c.Response().Code(http.StatusOK)
+		}).RateLimit(&RateLimit{Limit: 2, Period: time.Hour, Algorithm: algorithm})
+		codes := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}
+		for i, code := range codes {
+			rec := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/limited", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			f.router.ServeHTTP(rec, req)
+			if rec.Code != code {
+				t.Fatal(algorithm, "request", i, "returned", rec.Code, "instead of", code)
+			}
+			if code == http.StatusTooManyRequests && rec.Header().Get(RetryAfter) == "" {
+				t.Fatal(algorithm, "expected a Retry-After header")
+			}
+		}
+		// other clients have their own quota
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/limited", nil)
+		req.RemoteAddr = "10.0.0.2:1234"
+		f.router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || rec.Header().Get(RateLimitRemaining) != "1" {
+			t.Fatal(algorithm, "expected a new quota, returned", rec.Code, rec.Header().Get(RateLimitRemaining))
+		}
+	}
+}
+
+func TestRateLimit_User(t *testing.T) {
+	f := setup()
+	f.config.RateLimit = &RateLimit{Limit: 1, Period: time.Hour, Key: "user"}
+	f.GET("/me", func(c Context) error {
+		return c.Response().Code(http.StatusOK)
+	}).Before(BasicAuth("fresh", func(user string) (string, bool) {
+		return "secret", true
+	}))
+	codes := map[string][]int{"a": {http.StatusOK, http.StatusTooManyRequests}, "b": {http.StatusOK}}
+	for _, user := range []string{"a", "b"} {
+		for i, code := range codes[user] {
+			rec := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/me", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			req.SetBasicAuth(user, "secret")
+			f.router.ServeHTTP(rec, req)
+			if rec.Code != code {
+				t.Fatal("User", user, "request", i, "returned", rec.Code, "instead of", code)
+			}
+		}
+	}
+}
+
+type testPrincipal struct {
+	id    string
+	roles []string
+}
+
+func (p testPrincipal) ID() string            { return p.id }
+func (p testPrincipal) Permissions() []string { return p.roles }
+
+func TestRateLimit_Principal(t *testing.T) {
+	tests := []struct {
+		principal interface{}
+		key       string
+	}{
+		{"alice", "alice"},
+		{Claims{"sub": "bob"}, "bob"},
+		{Claims{"iss": "fresh"}, "10.0.0.1"},
+		{testPrincipal{id: "carol", roles: []string{"admin"}}, "carol"},
+		{[]string{"admin"}, "10.0.0.1"},
+	}
+	l := &RateLimit{Key: "user"}
+	for i, test := range tests {
+		f := setup()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		c := &context{config: f.config}
+		c.init(req, httptest.NewRecorder())
+		c.Set(ContextPrincipal, test.principal)
+		if key := l.key(c); key != test.key {
+			t.Fatal("Principal", i, "returned the key", key, "instead of", test.key)
+		}
+	}
+}
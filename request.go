@@ -2,7 +2,9 @@ package fresh
 
 import (
 	"encoding/json"
+	"errors"
 	"golang.org/x/net/websocket"
+	"google.golang.org/protobuf/proto"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -14,15 +16,24 @@ import (
 type (
 	Request interface {
 		setRouteParam(map[string]string)
+		limit(int64) error
 
 		IsWS() bool
 		IsTSL() bool
+		Host() string
+		RealIP() string
+		Scheme() string
 		Auth() string
 		AuthBearer() string
 		URL() *url.URL
 		Method() string
 		Header() http.Header
+		Bind(interface{}) error
+		XML(interface{}) error
 		JSON(interface{}) error
+		YAML(interface{}) error
+		MsgPack(interface{}) error
+		Protobuf(proto.Message) error
 		JSONraw() map[string]interface{}
 		Form() url.Values
 		Get() *http.Request
@@ -43,18 +54,18 @@ type (
 	}
 )
 
+// ErrRequestEntityTooLarge returned when the request body exceed the configured limit
+var ErrRequestEntityTooLarge = errors.New(http.StatusText(http.StatusRequestEntityTooLarge))
+
 // IsTSL check for a web socket request
 func (req *request) IsWS() bool {
 	h := req.r.Header.Get(Upgrade)
 	return h == "websocket" || h == "Websocket"
 }
 
-// IsTSL check for a tsl request
+// IsTSL check for a tsl request, directly or through a trusted proxy
 func (req *request) IsTSL() bool {
-	if req.r.TLS != nil {
-		return true
-	}
-	return false
+	return req.Scheme() == "https"
 }
 
 // Auth return request header authorization
@@ -102,6 +113,45 @@ func (req *request) JSON(i interface{}) error {
 	return nil
 }
 
+// Bind decode the body in a given interface using the decoder registered for the request Content-Type
+func (req *request) Bind(i interface{}) error {
+	d := findDecoder(req.r.Header.Get(ContentType), req.config.decoders)
+	if d == nil {
+		req.response.set(http.StatusUnsupportedMediaType, nil)
+		return ErrUnsupportedMediaType
+	}
+	return d(req.r.Body, i)
+}
+
+// XML return a xml body mapped to a given interface
+func (req *request) XML(i interface{}) error {
+	return req.decode("application/xml", i)
+}
+
+// YAML return a yaml body mapped to a given interface
+func (req *request) YAML(i interface{}) error {
+	return req.decode("application/x-yaml", i)
+}
+
+// MsgPack return a msgpack body mapped to a given interface
+func (req *request) MsgPack(i interface{}) error {
+	return req.decode("application/msgpack", i)
+}
+
+// Protobuf return a protocol buffers body mapped to a given message
+func (req *request) Protobuf(m proto.Message) error {
+	return req.decode("application/x-protobuf", m)
+}
+
+// Decode the body with the decoder registered for a media type
+func (req *request) decode(content string, i interface{}) error {
+	d := findDecoder(content, req.config.decoders)
+	if d == nil {
+		return ErrUnsupportedMediaType
+	}
+	return d(req.r.Body, i)
+}
+
 // JSONraw return a json body mapped to a raw interface
 func (req *request) JSONraw() map[string]interface{} {
 	var raw map[string]interface{}
@@ -156,6 +206,23 @@ func (req *request) QueryParam(k string) string {
 	return req.r.URL.Query().Get(k)
 }
 
+// Limit the request body size, a route limit overrides the given one
+func (req *request) limit(max int64) error {
+	if req.handler != nil && req.handler.limit != "" {
+		max = size(req.handler.limit)
+	}
+	if max <= 0 || req.r.Body == nil {
+		return nil
+	}
+	if req.r.ContentLength > max {
+		req.context.response.set(http.StatusRequestEntityTooLarge, nil)
+		return ErrRequestEntityTooLarge
+	}
+	// chunked or lying requests fail while reading the body
+	req.r.Body = http.MaxBytesReader(req.context.response.w, req.r.Body, max)
+	return nil
+}
+
 // Set URL parameters
 func (req *request) setRouteParam(m map[string]string) {
 	req.p = m
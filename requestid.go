@@ -0,0 +1,94 @@
+package fresh
+
+import (
+	httpContext "context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+type (
+	// RequestID config, an incoming id is reused when valid otherwise a new one is generated
+	RequestID struct {
+		Header    string        `yaml:"header,omitempty"`
+		Generator func() string `yaml:"-"`
+	}
+
+	// Transport add the request id and the trace context of the outbound request context to its headers
+	Transport struct {
+		Header string
+		Base   http.RoundTripper
+	}
+
+	requestIDKey struct{}
+)
+
+// RequestIDFromContext return the request id stored in a Go context
+func RequestIDFromContext(ctx httpContext.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RoundTrip set the request id and traceparent headers, the request is cloned as required by the RoundTripper contract
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	header := t.Header
+	if header == "" {
+		header = XRequestID
+	}
+	id := RequestIDFromContext(r.Context())
+	span := SpanFromContext(r.Context())
+	if (id != "" && r.Header.Get(header) == "") || (span != nil && r.Header.Get(Traceparent) == "") {
+		r = r.Clone(r.Context())
+		if id != "" && r.Header.Get(header) == "" {
+			r.Header.Set(header, id)
+		}
+		if span != nil && r.Header.Get(Traceparent) == "" {
+			r.Header.Set(Traceparent, span.TraceParent())
+			if span.TraceState != "" {
+				r.Header.Set(Tracestate, span.TraceState)
+			}
+		}
+	}
+	return base.RoundTrip(r)
+}
+
+// ValidRequestID accept ids up to 128 chars made of letters, digits and - _ . : + / =
+func validRequestID(id string) bool {
+	if len(id) == 0 || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':' || c == '+' || c == '/' || c == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Apply set the request id in the context, in the response and in the Go context of the request
+func (rid *RequestID) apply(c *context, w http.ResponseWriter, r *http.Request) *http.Request {
+	header := rid.Header
+	if header == "" {
+		header = XRequestID
+	}
+	id := r.Header.Get(header)
+	if !validRequestID(id) {
+		if rid.Generator != nil {
+			id = rid.Generator()
+		} else {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+	}
+	w.Header().Set(header, id)
+	c.Set(ContextRequestID, id)
+	return r.WithContext(httpContext.WithValue(r.Context(), requestIDKey{}, id))
+}
@@ -0,0 +1,25 @@
+package fresh
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	f := setup()
+	f.config.RequestID = &RequestID{}
+	f.GET("/id", func(c Context) error {
+		return c.Response().Raw(http.StatusOK, c.Get(ContextRequestID).(string)+" "+RequestIDFromContext(c.Request().Get().Context()))
+	})
+	for incoming, reused := range map[string]bool{"abc-123": true, "": false, "bad id\n": false} {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/id", nil)
+		req.Header.Set(XRequestID, incoming)
+		f.router.ServeHTTP(rec, req)
+		id := rec.Header().Get(XRequestID)
+		if id == "" || rec.Body.String() != id+" "+id || (id == incoming) != reused {
+			t.Fatal("Incoming id", incoming, "returned", id, rec.Body.String())
+		}
+	}
+}
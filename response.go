@@ -1,11 +1,21 @@
 package fresh
 
 import (
+	"crypto/sha1"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v2"
 )
 
 type (
@@ -14,7 +24,11 @@ type (
 		get() reply
 		writeErr(error)
 		set(int, []byte)
+		conditional(bool)
 		Code(int) error
+		ETag(string)
+		Modified(time.Time)
+		Precondition(string, time.Time) error
 		Type(content string)
 		Raw(int, string) error
 		Error(int, error) error
@@ -22,9 +36,15 @@ type (
 		File(int, string) error
 		Get() http.ResponseWriter
 		Download(int, string) error
+		CSV(int, [][]string) error
+		CSVStream(int, func(func([]string) error) error) error
 		XML(int, interface{}) error
 		Text(int, interface{}) error
 		JSON(int, interface{}) error
+		YAML(int, interface{}) error
+		MsgPack(int, interface{}) error
+		Protobuf(int, proto.Message) error
+		Negotiate(int, interface{}) error
 		JSONP(int, string, interface{}) error
 		XMLFormat(int, interface{}, string) error
 		JSONFormat(int, interface{}, string) error
@@ -34,6 +54,7 @@ type (
 	reply struct {
 		code     int
 		response []byte
+		stream   func(io.Writer) error
 	}
 
 	response struct {
@@ -44,9 +65,21 @@ type (
 	}
 )
 
-// Return writer
+// ErrPreconditionFailed returned when a conditional request doesn't match the current resource state
+var ErrPreconditionFailed = errors.New(http.StatusText(http.StatusPreconditionFailed))
+
+// Return writer, a compressing writer is closed once the response is written
 func (r *response) write() {
+	if c, ok := r.w.(io.Closer); ok {
+		defer c.Close()
+	}
 	r.w.WriteHeader(r.reply.code)
+	if r.reply.stream != nil {
+		if err := r.reply.stream(r.w); err != nil {
+			r.Logger().Error("stream", "error", err)
+		}
+		return
+	}
 	r.w.Write(r.reply.response)
 }
 
@@ -57,6 +90,11 @@ func (r *response) get() reply {
 
 // WriteErr response http error
 func (r *response) writeErr(err error) {
+	var maxBytes *http.MaxBytesError
+	if errors.As(err, &maxBytes) {
+		r.reply.code = http.StatusRequestEntityTooLarge
+		err = ErrRequestEntityTooLarge
+	}
 	if r.reply.code == 0 {
 		r.reply.code = http.StatusInternalServerError
 	}
@@ -72,7 +110,7 @@ func (r *response) check(content string) {
 
 // Set response values
 func (r *response) set(code int, response []byte) {
-	r.reply = reply{code, response}
+	r.reply = reply{code: code, response: response}
 }
 
 // Http code response
@@ -168,6 +206,158 @@ func (r *response) Text(c int, i interface{}) error {
 	return nil
 }
 
+// Negotiate response, encode the value in the best media type accepted by the client
+func (r *response) Negotiate(c int, i interface{}) error {
+	vary(r.w.Header(), Accept)
+	e := negotiate(r.r.Header.Get(Accept), r.config.encoders)
+	if e == nil {
+		r.set(http.StatusNotAcceptable, nil)
+		return ErrNotAcceptable
+	}
+	d, err := e.encode(i)
+	if err != nil {
+		r.set(c, nil)
+		return err
+	}
+	r.check(e.content)
+	r.set(c, d)
+	return nil
+}
+
+// YAML response
+func (r *response) YAML(c int, i interface{}) error {
+	r.check(MIMEAppYAML)
+	d, err := yaml.Marshal(i)
+	if err != nil {
+		r.set(c, nil)
+		return err
+	}
+	r.set(c, d)
+	return nil
+}
+
+// MsgPack response
+func (r *response) MsgPack(c int, i interface{}) error {
+	r.check(MIMEAppMsgPack)
+	d, err := msgpack.Marshal(i)
+	if err != nil {
+		r.set(c, nil)
+		return err
+	}
+	r.set(c, d)
+	return nil
+}
+
+// Protobuf response
+func (r *response) Protobuf(c int, m proto.Message) error {
+	r.check(MIMEAppProto)
+	d, err := proto.Marshal(m)
+	if err != nil {
+		r.set(c, nil)
+		return err
+	}
+	r.set(c, d)
+	return nil
+}
+
+// CSV response
+func (r *response) CSV(c int, rows [][]string) error {
+	r.check(MIMETextCSV)
+	d, err := csvMarshal(rows)
+	if err != nil {
+		r.set(c, nil)
+		return err
+	}
+	r.set(c, d)
+	return nil
+}
+
+// CSVStream response, rows are written to the client as the iterator yields them
+func (r *response) CSVStream(c int, rows func(yield func([]string) error) error) error {
+	r.check(MIMETextCSV)
+	r.set(c, nil)
+	r.reply.stream = func(w io.Writer) error {
+		cw := csv.NewWriter(w)
+		i := 0
+		err := rows(func(row []string) error {
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+			// flush every chunk of rows
+			if i++; i%100 == 0 {
+				cw.Flush()
+				if f, ok := w.(http.Flusher); ok {
+					f.Flush()
+				}
+			}
+			return nil
+		})
+		cw.Flush()
+		if err != nil {
+			return err
+		}
+		return cw.Error()
+	}
+	return nil
+}
+
+// ETag set the response entity tag, a weak one is generated when it isn't set
+func (r *response) ETag(tag string) {
+	r.w.Header().Set(ETag, quoteETag(tag))
+}
+
+// Modified set the response last modified time
+func (r *response) Modified(t time.Time) {
+	if !t.IsZero() {
+		r.w.Header().Set(LastModified, t.UTC().Format(http.TimeFormat))
+	}
+}
+
+// Precondition check If-Match and If-Unmodified-Since against the current resource state
+func (r *response) Precondition(tag string, modified time.Time) error {
+	if match := r.r.Header.Get(IfMatch); match != "" {
+		if tag == "" || !matchETag(match, quoteETag(tag), false) {
+			return r.Error(http.StatusPreconditionFailed, ErrPreconditionFailed)
+		}
+		return nil
+	}
+	if since, err := http.ParseTime(r.r.Header.Get(IfUnmodifiedSince)); err == nil && !modified.IsZero() {
+		if modified.Truncate(time.Second).After(since) {
+			return r.Error(http.StatusPreconditionFailed, ErrPreconditionFailed)
+		}
+	}
+	return nil
+}
+
+// Conditional evaluate If-None-Match and If-Modified-Since for a buffered response
+func (r *response) conditional(generate bool) {
+	if r.r.Method != "GET" && r.r.Method != "HEAD" {
+		return
+	}
+	if r.reply.code != http.StatusOK || r.reply.stream != nil {
+		return
+	}
+	h := r.w.Header()
+	if h.Get(ETag) == "" && generate && len(r.reply.response) > 0 {
+		sum := sha1.Sum(r.reply.response)
+		h.Set(ETag, "W/\""+hex.EncodeToString(sum[:16])+"\"")
+	}
+	modified := false
+	if match := r.r.Header.Get(IfNoneMatch); match != "" {
+		modified = !matchETag(match, h.Get(ETag), true)
+	} else if since, err := http.ParseTime(r.r.Header.Get(IfModifiedSince)); err == nil {
+		last, err := http.ParseTime(h.Get(LastModified))
+		modified = err != nil || last.After(since)
+	} else {
+		return
+	}
+	if !modified {
+		h.Del(ContentType)
+		h.Del(ContentLength)
+		r.set(http.StatusNotModified, nil)
+	}
+}
+
 // Custom Header
 func (r *response) Header(key, value string) Response {
 	r.w.Header().Add(key, value)
@@ -3,6 +3,7 @@ package fresh
 import (
 	"golang.org/x/net/websocket"
 	"strings"
+	"time"
 )
 
 type Rest interface {
@@ -23,6 +24,18 @@ func (f *fresh) WS(path string, handler HandlerFunc) Handler {
 	h := func(c Context) (err error) {
 		websocket.Handler(func(ws *websocket.Conn) {
 			defer ws.Close()
+			// hijacked connections are drained by Stop
+			if !f.lifecycle.track(ws) {
+				return
+			}
+			defer f.lifecycle.untrack(ws)
+			// the server read and write timeouts don't apply to hijacked connections
+			ws.SetDeadline(time.Time{})
+			if ctx, ok := c.(*context); ok && f.config.Metrics != nil {
+				route := ctx.handler.route.pattern()
+				f.config.Metrics.connected(route, 1)
+				defer f.config.Metrics.connected(route, -1)
+			}
 			c.Request().SetWS(ws)
 			err = handler(c)
 		}).ServeHTTP(c.Response().Get(), c.Request().Get())
@@ -0,0 +1,226 @@
+package fresh
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Log file rotated by size or time, backups are named path.timestamp and reopened on SIGHUP
+type rotateWriter struct {
+	sync.Mutex
+	path     string
+	maxSize  int64
+	every    time.Duration
+	backups  int
+	maxAge   time.Duration
+	compress bool
+	file     *os.File
+	size     int64
+	next     time.Time
+	hup      chan os.Signal
+	queue    chan string
+	done     chan struct{}
+	stopped  chan struct{}
+	stop     sync.Once
+}
+
+// Backup timestamp layout, sortable
+const backupLayout = "20060102T150405.000000000"
+
+// NewRotateWriter open a log file with the rotation settings of the logs config
+func newRotateWriter(path string, l Logs) (*rotateWriter, error) {
+	w := &rotateWriter{
+		path:     path,
+		maxSize:  size(l.MaxSize),
+		every:    l.Rotate,
+		backups:  l.MaxBackups,
+		maxAge:   l.MaxAge,
+		compress: l.Compress,
+		hup:      make(chan os.Signal, 1),
+		queue:    make(chan string, 16),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	signal.Notify(w.hup, syscall.SIGHUP)
+	go w.loop()
+	return w, nil
+}
+
+// Loop reopen the file on SIGHUP and compress the rotated files off the write path
+func (w *rotateWriter) loop() {
+	defer close(w.stopped)
+	for {
+		select {
+		case <-w.hup:
+			w.Reopen()
+		case backup := <-w.queue:
+			w.archive(backup)
+		case <-w.done:
+			// compress the files already rotated
+			for {
+				select {
+				case backup := <-w.queue:
+					w.archive(backup)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// Open the log file in append mode
+func (w *rotateWriter) open() error {
+	f, err := os.OpenFile(w.path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	w.file, w.size = f, 0
+	if fi, err := f.Stat(); err == nil {
+		w.size = fi.Size()
+	}
+	if w.every > 0 {
+		w.next = time.Now().Truncate(w.every).Add(w.every)
+	}
+	return nil
+}
+
+// Write rotate the file when the size or the time limit is reached
+func (w *rotateWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	if (w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize) || (w.every > 0 && !time.Now().Before(w.next)) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Reopen the log file, used after an external rotation
+func (w *rotateWriter) Reopen() error {
+	w.Lock()
+	defer w.Unlock()
+	w.close()
+	return w.open()
+}
+
+// Close the log file, stop the SIGHUP handling and wait for the pending compressions
+func (w *rotateWriter) Close() error {
+	w.stop.Do(func() {
+		signal.Stop(w.hup)
+		close(w.done)
+	})
+	w.Lock()
+	err := w.close()
+	w.Unlock()
+	<-w.stopped
+	return err
+}
+
+// Close the current file, the lock must be held
+func (w *rotateWriter) close() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// Rotate rename the current file and queue its compression, the expired backups are removed
+func (w *rotateWriter) rotate() error {
+	w.close()
+	backup := w.path + "." + time.Now().Format(backupLayout)
+	if err := os.Rename(w.path, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if !w.compress {
+		w.clean()
+		return w.open()
+	}
+	select {
+	case w.queue <- backup:
+	default:
+		// the queue is full or the loop stopped
+		w.archive(backup)
+	}
+	return w.open()
+}
+
+// Archive compress a rotated file and remove the expired backups
+func (w *rotateWriter) archive(backup string) {
+	if err := compressFile(backup); err == nil {
+		os.Remove(backup)
+	}
+	w.clean()
+}
+
+// Clean remove the backups over max backups or older than max age
+func (w *rotateWriter) clean() {
+	if w.backups <= 0 && w.maxAge <= 0 {
+		return
+	}
+	matches, _ := filepath.Glob(w.path + ".*")
+	var backups []string
+	for _, m := range matches {
+		if _, err := time.Parse(backupLayout, strings.TrimSuffix(strings.TrimPrefix(m, w.path+"."), ".gz")); err == nil {
+			backups = append(backups, m)
+		}
+	}
+	// newest first
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	for i, b := range backups {
+		expired := false
+		if w.maxAge > 0 {
+			if fi, err := os.Stat(b); err == nil && time.Since(fi.ModTime()) > w.maxAge {
+				expired = true
+			}
+		}
+		if expired || (w.backups > 0 && i >= w.backups) {
+			os.Remove(b)
+		}
+	}
+}
+
+// CompressFile gzip a file in path.gz
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err == nil {
+		err = gz.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+	}
+	return err
+}
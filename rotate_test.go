@@ -0,0 +1,48 @@
+package fresh
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotateWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "fresh.logs")
+	w, err := newRotateWriter(path, Logs{MaxSize: "10B", MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err := w.Write([]byte("0123456789")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// external rotation, the file is reopened at the same path
+	os.Rename(path, path+".old")
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("new"))
+	content, _ := ioutil.ReadFile(path)
+	if !strings.HasPrefix(string(content), "new") {
+		t.Fatal("Expected the reopened file to be written instead of", string(content))
+	}
+	// close wait for the background compression
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	backups, _ := filepath.Glob(path + ".*.gz")
+	if len(backups) != 2 {
+		t.Fatal("Expected 2 compressed backups instead of", backups)
+	}
+	if raw, _ := filepath.Glob(path + ".2*[0-9]"); len(raw) != 0 {
+		t.Fatal("Expected the rotated files to be removed once compressed", raw)
+	}
+}
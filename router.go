@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 type (
@@ -30,12 +31,25 @@ type (
 	Handler interface {
 		After(...HandlerFunc) Handler
 		Before(...HandlerFunc) Handler
+		CORS(*CORS) Handler
+		Limit(string) Handler
+		Require(...string) Handler
+		Firewall(*Firewall) Handler
+		RateLimit(*RateLimit) Handler
+		Cache(time.Duration, ...string) Handler
 	}
 	handler struct {
-		method string
-		ctrl   HandlerFunc
-		before []HandlerFunc
-		after  []HandlerFunc
+		route     *route
+		method    string
+		limit     string
+		ctrl      HandlerFunc
+		cors      *CORS
+		cache     *cacheRule
+		require   []string
+		firewall  *Firewall
+		ratelimit *RateLimit
+		before    []HandlerFunc
+		after     []HandlerFunc
 	}
 
 	// Resource struct
@@ -46,6 +60,12 @@ type (
 	Resource interface {
 		After(...HandlerFunc) Resource
 		Before(...HandlerFunc) Resource
+		CORS(*CORS) Resource
+		Limit(string) Resource
+		Require(...string) Resource
+		Firewall(*Firewall) Resource
+		RateLimit(*RateLimit) Resource
+		Cache(time.Duration, ...string) Resource
 	}
 )
 
@@ -66,6 +86,14 @@ func (r *route) getHandler(method string) *handler {
 	return nil
 }
 
+// Pattern return the full path of a route, parameters included
+func (r *route) pattern() string {
+	if r.parent == nil {
+		return "/" + strings.Trim(r.path, "/")
+	}
+	return strings.TrimSuffix(r.parent.pattern(), "/") + "/" + r.path
+}
+
 // Add handlers to a route
 func (r *route) addHandler(method string, controller HandlerFunc, middleware ...HandlerFunc) Handler {
 	// If already exist an entry for the method change related handler
@@ -75,32 +103,47 @@ func (r *route) addHandler(method string, controller HandlerFunc, middleware ...
 			return h
 		}
 	}
-	h := handler{method: method, ctrl: controller}
+	h := handler{route: r, method: method, ctrl: controller}
 	r.handlers = append(r.handlers, &h)
 	return &h
 }
 
 // Process a request
 func (r *router) process(handler *handler, response http.ResponseWriter, request *http.Request, context *context) (err error) {
+	context.handler = handler
 	context.init(request, response)
-	// TODO improve layout
-	// log route stdout
-	defer func() {
-		r.config.log(request.Method, request.RequestURI, context.response.reply.code)
-	}()
-
+	// config handlers run before the route middleware
+	if err = handler.middleware(context, r.config.before...); err != nil {
+		return err
+	}
+	// route firewall
+	if handler.firewall != nil {
+		if err = handler.firewall.check(context); err != nil {
+			return err
+		}
+	}
 	if err = handler.middleware(context, handler.before...); err != nil {
 		return err
 	}
-	// route controller
-	err = handler.ctrl(context)
-	if err != nil {
+	// route requirements, the principal is set by the before middleware
+	if err = r.authorize(handler, context); err != nil {
 		return err
 	}
-	// after middleware
-	if err = handler.middleware(context, handler.after...); err != nil {
+	if err = r.throttle(handler, context); err != nil {
 		return err
 	}
+	// replay a cached response or run the route controller
+	if !context.response.replay(handler.cache) {
+		err = handler.ctrl(context)
+		if err != nil {
+			return err
+		}
+		// after middleware
+		if err = handler.middleware(context, handler.after...); err != nil {
+			return err
+		}
+		context.response.store(handler.cache)
+	}
 	// loop handlers
 	for _, ch := range r.config.handlers {
 		err := ch(context)
@@ -133,6 +176,54 @@ func (r *resource) Before(middleware ...HandlerFunc) Resource {
 	return r
 }
 
+// Cache the GET responses of a resource
+func (r *resource) Cache(ttl time.Duration, tags ...string) Resource {
+	for _, route := range r.rest {
+		route.Cache(ttl, tags...)
+	}
+	return r
+}
+
+// CORS policy of a resource, overrides the config one
+func (r *resource) CORS(policy *CORS) Resource {
+	for _, route := range r.rest {
+		route.CORS(policy)
+	}
+	return r
+}
+
+// Limit the request body size of a resource
+func (r *resource) Limit(body string) Resource {
+	for _, route := range r.rest {
+		route.Limit(body)
+	}
+	return r
+}
+
+// Require roles or permissions for all the resource routes
+func (r *resource) Require(permissions ...string) Resource {
+	for _, route := range r.rest {
+		route.Require(permissions...)
+	}
+	return r
+}
+
+// Firewall for all the resource routes
+func (r *resource) Firewall(fw *Firewall) Resource {
+	for _, route := range r.rest {
+		route.Firewall(fw)
+	}
+	return r
+}
+
+// RateLimit all the resource routes
+func (r *resource) RateLimit(limit *RateLimit) Resource {
+	for _, route := range r.rest {
+		route.RateLimit(limit)
+	}
+	return r
+}
+
 // Run a middleware
 func (h *handler) middleware(c Context, handlers ...HandlerFunc) error {
 	for _, f := range handlers {
@@ -161,6 +252,48 @@ func (h *handler) Before(middleware ...HandlerFunc) Handler {
 	return h
 }
 
+// Cache the responses of a single route, a zero ttl use the config or Cache-Control max-age
+func (h *handler) Cache(ttl time.Duration, tags ...string) Handler {
+	h.cache = &cacheRule{ttl: ttl, tags: tags}
+	return h
+}
+
+// CORS policy of a single route, overrides the config one
+func (h *handler) CORS(policy *CORS) Handler {
+	if policy != nil {
+		h.cors = policy
+	}
+	return h
+}
+
+// Limit the request body size of a single route, overrides the config limit
+func (h *handler) Limit(body string) Handler {
+	h.limit = body
+	return h
+}
+
+// Require roles or permissions to access a single route
+func (h *handler) Require(permissions ...string) Handler {
+	h.require = append(h.require, permissions...)
+	return h
+}
+
+// Firewall a single route, checked after the config firewall
+func (h *handler) Firewall(fw *Firewall) Handler {
+	if fw != nil {
+		h.firewall = fw
+	}
+	return h
+}
+
+// RateLimit a single route, applied after the before middleware and the config rate limit
+func (h *handler) RateLimit(limit *RateLimit) Handler {
+	if limit != nil {
+		h.ratelimit = limit
+	}
+	return h
+}
+
 // Register static routes for assets
 func (r *router) addStatic(static map[string]string) Handler {
 	for k, v := range static {
@@ -170,7 +303,7 @@ func (r *router) addStatic(static map[string]string) Handler {
 }
 
 // Router main function. Find the matching route and call registered handlers.
-func (r *router) serveStatic(response http.ResponseWriter, request *http.Request) {
+func (r *router) serveStatic(response http.ResponseWriter, request *http.Request, context *context) {
 	for publicPath, staticPath := range r.static {
 		path := strings.Replace(strings.Trim(request.URL.Path, "/"), publicPath, staticPath, 1)
 		path, _ = filepath.Abs(path)
@@ -189,9 +322,6 @@ func (r *router) serveStatic(response http.ResponseWriter, request *http.Request
 
 		}
 	}
-	// TODO improve layout
-	// log route stdout
-	r.config.log(request.Method, request.RequestURI, 404)
 	// write response
 	http.NotFound(response, request)
 }
@@ -245,12 +375,103 @@ func (r *router) register(parent *route, path []string, context *context) *route
 	return parent
 }
 
+// Override the method of a POST request from the X-HTTP-Method-Override header, the _method query parameter or urlencoded field
+func (r *router) override(response http.ResponseWriter, request *http.Request) {
+	o := r.config.Override
+	if o == nil || request.Method != "POST" {
+		return
+	}
+	method := request.Header.Get(XHTTPMethodOverride)
+	if method == "" {
+		method = request.URL.Query().Get("_method")
+	}
+	if method == "" && mime(request.Header.Get(ContentType)) == MIMEUrlencoded {
+		// parsing the form read the body, apply the route or the config limit first
+		limit := ""
+		if r.config.Limit != nil {
+			limit = r.config.Limit.Body
+		}
+		context := &context{parameters: make(map[string]string)}
+		if route := r.findNode(r.route, strings.Split(strings.Trim(request.URL.Path, "/"), "/"), context); route != nil {
+			if h := route.getHandler("POST"); h != nil && h.limit != "" {
+				limit = h.limit
+			}
+		}
+		if size(limit) > 0 {
+			request.Body = http.MaxBytesReader(response, request.Body, size(limit))
+		}
+		if request.ParseForm() == nil {
+			method = request.PostForm.Get("_method")
+		}
+	}
+	method = strings.ToUpper(strings.TrimSpace(method))
+	allowed := o.Methods
+	if len(allowed) == 0 {
+		allowed = []string{"PUT", "PATCH", "DELETE"}
+	}
+	for _, m := range allowed {
+		if strings.ToUpper(m) == method {
+			request.Method = method
+			return
+		}
+	}
+}
+
 // Router main function. Find the matching route and call registered handlers.
 func (r *router) ServeHTTP(response http.ResponseWriter, request *http.Request) {
-	context := &context{}
+	r.override(response, request)
+	start := time.Now()
+	writer := &accessWriter{ResponseWriter: response}
+	response = writer
+	context := &context{config: r.config}
 	context.parameters = make(map[string]string)
+	if r.config.RequestID != nil {
+		request = r.config.RequestID.apply(context, response, request)
+	}
+	if r.config.Tracing != nil {
+		request = r.config.Tracing.start(context, request)
+		defer r.config.Tracing.end(context, writer)
+	}
+	context.init(request, response)
+	defer r.access(context, writer, start)
+	// security headers on every response, static files and not found included
+	if r.config.Security != nil {
+		r.config.Security.headers(context)
+	}
+	// config firewall, static files and not found included
+	if r.config.Firewall != nil {
+		if err := r.config.Firewall.check(context); err != nil {
+			context.Response().writeErr(err)
+			return
+		}
+	}
+	if h := r.health(request); h != nil {
+		if err := r.process(h, response, request, context); err != nil {
+			context.Response().writeErr(err)
+		}
+		return
+	}
+	if m := r.config.Metrics; m != nil {
+		m.begin(request.Method)
+		defer m.observe(context, writer, start)
+		if h := m.handler(request); h != nil {
+			if err := r.process(h, response, request, context); err != nil {
+				context.Response().writeErr(err)
+			}
+			return
+		}
+	}
 	splittedPath := strings.Split(strings.Trim(request.URL.Path, "/"), "/")
 	if route := r.findNode(r.route, splittedPath, context); route != nil {
+		if preflight(request) {
+			if h := r.preflight(route, request); h != nil {
+				err := r.process(h, response, request, context)
+				if err != nil {
+					context.Response().writeErr(err)
+				}
+				return
+			}
+		}
 		if r.config.Options && request.Method == "OPTIONS" {
 			h := &handler{
 				ctrl: func(c Context) error {
@@ -271,5 +492,5 @@ func (r *router) ServeHTTP(response http.ResponseWriter, request *http.Request)
 			return
 		}
 	}
-	r.serveStatic(response, request)
+	r.serveStatic(response, request, context)
 }
@@ -0,0 +1,51 @@
+package fresh
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouter_Override(t *testing.T) {
+	f := setup()
+	f.config.Override = &Override{Methods: []string{"PUT", "DELETE"}}
+	f.PUT("/override", func(c Context) error {
+		return c.Response().Code(http.StatusNoContent)
+	})
+	f.POST("/override", func(c Context) error {
+		return c.Response().Code(http.StatusCreated)
+	})
+	f.PATCH("/override", func(c Context) error {
+		return c.Response().Code(http.StatusOK)
+	})
+	f.POST("/small", func(c Context) error {
+		return c.Response().Code(http.StatusCreated)
+	}).Limit("8B")
+	tests := []struct {
+		method string
+		path   string
+		header string
+		ct     string
+		form   string
+		code   int
+	}{
+		{"POST", "/override", "PUT", MIMEUrlencoded, "", http.StatusNoContent},
+		{"POST", "/override", "", MIMEUrlencoded, "_method=put", http.StatusNoContent},
+		{"POST", "/override?_method=delete", "", MIMEUrlencoded, "", http.StatusNotFound},
+		{"POST", "/override", "PATCH", MIMEUrlencoded, "", http.StatusCreated},
+		{"GET", "/override", "PUT", MIMEUrlencoded, "", http.StatusNotFound},
+		{"POST", "/override", "", MIMEMultipart + "; boundary=x", "--x\r\nContent-Disposition: form-data; name=\"_method\"\r\n\r\nPUT\r\n--x--\r\n", http.StatusCreated},
+		{"POST", "/small", "", MIMEUrlencoded, "field=value&_method=put", http.StatusRequestEntityTooLarge},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, test.path, strings.NewReader(test.form))
+		req.Header.Set(ContentType, test.ct)
+		req.Header.Set(XHTTPMethodOverride, test.header)
+		f.router.ServeHTTP(rec, req)
+		if rec.Code != test.code {
+			t.Fatal(test.method, test.path, test.header, test.form, "returned", rec.Code, "instead of", test.code)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package fresh
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	csrfName   = "_csrf"
+	csrfMaxAge = 86400
+)
+
+// ErrCSRF returned when the csrf token is missing or doesn't match
+var ErrCSRF = errors.New("invalid csrf token")
+
+// NewCSP return an empty content security policy
+func NewCSP() *CSP {
+	return &CSP{Directives: make(map[string][]string)}
+}
+
+// Add sources to a policy directive
+func (p *CSP) Add(directive string, sources ...string) *CSP {
+	if p.Directives == nil {
+		p.Directives = make(map[string][]string)
+	}
+	p.Directives[directive] = append(p.Directives[directive], sources...)
+	return p
+}
+
+// String build the policy, the nonce is added to script and style sources
+func (p *CSP) String(nonce string) string {
+	directives := make(map[string][]string, len(p.Directives))
+	for k, v := range p.Directives {
+		directives[k] = v
+	}
+	if nonce != "" {
+		source := "'nonce-" + nonce + "'"
+		added := false
+		for _, d := range []string{"script-src", "style-src"} {
+			if v, ok := directives[d]; ok {
+				directives[d] = append(append([]string(nil), v...), source)
+				added = true
+			}
+		}
+		if !added {
+			directives["script-src"] = []string{source}
+		}
+	}
+	keys := make([]string, 0, len(directives))
+	for k := range directives {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	policy := make([]string, 0, len(keys))
+	for _, k := range keys {
+		policy = append(policy, strings.TrimSpace(k+" "+strings.Join(directives[k], " ")))
+	}
+	return strings.Join(policy, "; ")
+}
+
+// Nonce return a random base64 value
+func nonce() string {
+	return random(16, base64.StdEncoding)
+}
+
+// Random return n random bytes encoded with a given encoding
+func random(n int, enc *base64.Encoding) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return enc.EncodeToString(b)
+}
+
+// Permissions build a Permissions-Policy header, self and * are kept, origins are quoted
+func permissions(features map[string][]string) string {
+	keys := make([]string, 0, len(features))
+	for k := range features {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	policy := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if contain("*", features[k]) {
+			policy = append(policy, k+"=*")
+			continue
+		}
+		list := make([]string, 0, len(features[k]))
+		for _, v := range features[k] {
+			if v == "self" || v == "'self'" {
+				list = append(list, "self")
+			} else {
+				list = append(list, strconv.Quote(v))
+			}
+		}
+		policy = append(policy, k+"=("+strings.Join(list, " ")+")")
+	}
+	return strings.Join(policy, ", ")
+}
+
+// Headers set the configured security headers on a response
+func (s *Security) headers(c Context) {
+	h := c.Response().Get().Header()
+	set := func(k, v string) {
+		if v != "" {
+			h.Set(k, v)
+		}
+	}
+	set(XXSSProtection, s.XSS)
+	set(XFrameOptions, s.XFrame)
+	set(XContentTypeOptions, s.XContentType)
+	set(ReferrerPolicy, s.ReferrerPolicy)
+	set(CrossOriginOpenerPolicy, s.COOP)
+	set(CrossOriginEmbedderPolicy, s.COEP)
+	set(CrossOriginResourcePolicy, s.CORP)
+	if s.XDNS {
+		h.Set(XDNSPrefetchControl, "on")
+	} else {
+		h.Set(XDNSPrefetchControl, "off")
+	}
+	if s.HSTS > 0 && c.Request().IsTSL() {
+		h.Set(StrictTransportSecurity, "max-age="+strconv.Itoa(s.HSTS)+"; includeSubDomains")
+	}
+	if len(s.Permissions) > 0 {
+		h.Set(PermissionsPolicy, permissions(s.Permissions))
+	}
+	if s.CSP != nil {
+		n := ""
+		if s.CSP.Nonce {
+			n = nonce()
+			c.Set(ContextNonce, n)
+		}
+		if s.CSP.ReportOnly {
+			h.Set(ContentSecurityPolicyReportOnly, s.CSP.String(n))
+		} else {
+			h.Set(ContentSecurityPolicy, s.CSP.String(n))
+		}
+	}
+}
+
+// Check the csrf token of unsafe requests and issue a new one if missing
+func (s *CSRF) check(c Context) error {
+	req := c.Request().Get()
+	if s.exempt(c) {
+		return nil
+	}
+	token := s.token(c)
+	if token == "" {
+		token = random(32, base64.RawURLEncoding)
+		s.save(c, token)
+	}
+	// expose the token to templates and json clients
+	c.Set(ContextCSRF, token)
+	c.Response().Get().Header().Set(s.header(), token)
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return nil
+	}
+	sent := req.Header.Get(s.header())
+	if sent == "" {
+		field := s.Field
+		if field == "" {
+			field = csrfName
+		}
+		sent = req.FormValue(field)
+	}
+	if sent == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+		return c.Response().Error(http.StatusForbidden, ErrCSRF)
+	}
+	return nil
+}
+
+// Exempt check the exempted paths and filters, a path ending with * match all the sub paths
+func (s *CSRF) exempt(c Context) bool {
+	for _, f := range s.Filters {
+		if f(c) {
+			return true
+		}
+	}
+	path := c.Request().URL().Path
+	pattern := ""
+	if ctx, ok := c.(*context); ok && ctx.handler != nil && ctx.handler.route != nil {
+		pattern = ctx.handler.route.pattern()
+	}
+	for _, e := range s.Exempt {
+		if strings.HasSuffix(e, "*") && strings.HasPrefix(path, strings.TrimSuffix(e, "*")) {
+			return true
+		}
+		if e == path || e == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// Header name used to send the token
+func (s *CSRF) header() string {
+	if s.Header != "" {
+		return s.Header
+	}
+	return XCSRFToken
+}
+
+// Token return the current token from the session or the cookie
+func (s *CSRF) token(c Context) string {
+	if s.Session != nil {
+		return s.Session.Get(c)
+	}
+	name := s.Cookie
+	if name == "" {
+		name = csrfName
+	}
+	cookie, err := c.Request().Get().Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// Save a new token in the session or in a cookie
+func (s *CSRF) save(c Context, token string) {
+	if s.Session != nil {
+		s.Session.Set(c, token)
+		return
+	}
+	cookie := &http.Cookie{
+		Name:     s.Cookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   s.MaxAge,
+		Secure:   s.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if cookie.Name == "" {
+		cookie.Name = csrfName
+	}
+	if cookie.MaxAge == 0 {
+		cookie.MaxAge = csrfMaxAge
+	}
+	switch strings.ToLower(s.SameSite) {
+	case "strict":
+		cookie.SameSite = http.SameSiteStrictMode
+	case "none":
+		cookie.SameSite = http.SameSiteNoneMode
+	}
+	http.SetCookie(c.Response().Get(), cookie)
+}
@@ -0,0 +1,96 @@
+package fresh
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSecurity_Headers(t *testing.T) {
+	f := setup()
+	f.config.Security = &Security{
+		XFrame:       "DENY",
+		XContentType: "nosniff",
+		HSTS:         3600,
+		CSP:          NewCSP().Add("default-src", "'self'").Add("script-src", "'self'"),
+		Permissions:  map[string][]string{"camera": {}, "geolocation": {"self", "https://maps.example.com"}},
+		COOP:         "same-origin",
+	}
+	f.config.Security.CSP.Nonce = true
+	var nonce interface{}
+	f.GET("/secure", func(c Context) error {
+		nonce = c.Get(ContextNonce)
+		return c.Response().Code(http.StatusOK)
+	})
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/secure", nil)
+	f.router.ServeHTTP(rec, req)
+	expected := map[string]string{
+		XFrameOptions:           "DENY",
+		XContentTypeOptions:     "nosniff",
+		XDNSPrefetchControl:     "off",
+		CrossOriginOpenerPolicy: "same-origin",
+		PermissionsPolicy:       `camera=(), geolocation=(self "https://maps.example.com")`,
+		ContentSecurityPolicy:   fmt.Sprintf("default-src 'self'; script-src 'self' 'nonce-%s'", nonce),
+		StrictTransportSecurity: "",
+	}
+	for k, v := range expected {
+		if rec.Header().Get(k) != v {
+			t.Error("Header", k, "is", rec.Header().Get(k), "instead of", v)
+		}
+	}
+	// not found and static responses are covered too
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/missing", nil)
+	f.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound || rec.Header().Get(XFrameOptions) != "DENY" {
+		t.Error("Expected the security headers on a not found response", rec.Code, rec.Header())
+	}
+}
+
+func TestSecurity_CSRF(t *testing.T) {
+	f := setup()
+	f.config.Security = &Security{CSRF: &CSRF{Exempt: []string{"/hooks/*"}}}
+	f.GET("/form", func(c Context) error {
+		return c.Response().Raw(http.StatusOK, c.Get(ContextCSRF).(string))
+	})
+	f.POST("/form", func(c Context) error {
+		return c.Response().Code(http.StatusCreated)
+	})
+	f.POST("/hooks/:id", func(c Context) error {
+		return c.Response().Code(http.StatusCreated)
+	})
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/form", nil)
+	f.router.ServeHTTP(rec, req)
+	token := rec.Body.String()
+	cookie := rec.Result().Cookies()[0]
+	if token == "" || rec.Header().Get(XCSRFToken) != token || cookie.Value != token {
+		t.Fatal("Expected a csrf token")
+	}
+	tests := []struct {
+		path   string
+		header string
+		form   string
+		code   int
+	}{
+		{"/form", "", "", http.StatusForbidden},
+		{"/form", "wrong", "", http.StatusForbidden},
+		{"/form", token, "", http.StatusCreated},
+		{"/form", "", "_csrf=" + token, http.StatusCreated},
+		{"/hooks/1", "", "", http.StatusCreated},
+	}
+	for _, test := range tests {
+		rec = httptest.NewRecorder()
+		req, _ = http.NewRequest("POST", test.path, strings.NewReader(test.form))
+		req.Header.Set(ContentType, MIMEUrlencoded)
+		req.Header.Set(XCSRFToken, test.header)
+		req.AddCookie(cookie)
+		f.router.ServeHTTP(rec, req)
+		if rec.Code != test.code {
+			t.Fatal("Path", test.path, "returned", rec.Code, "instead of", test.code)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package fresh
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/netutil"
+)
+
+// HTTPServer config, timeouts and connections limits of the http server
+type HTTPServer struct {
+	ReadTimeout       time.Duration `yaml:"read-timeout,omitempty"`
+	ReadHeaderTimeout time.Duration `yaml:"read-header-timeout,omitempty"`
+	WriteTimeout      time.Duration `yaml:"write-timeout,omitempty"` // unlimited when zero, long streams and websockets aren't cut
+	IdleTimeout       time.Duration `yaml:"idle-timeout,omitempty"`
+	MaxHeaderBytes    string        `yaml:"max-header-bytes,omitempty"` // as 1MB, overrides limit header
+	DisableKeepAlive  bool          `yaml:"disable-keep-alive,omitempty"`
+	MaxConns          int           `yaml:"max-conns,omitempty"` // concurrent connections, unlimited when zero
+}
+
+// Server defaults, slow clients can't hold a connection forever
+const (
+	readTimeout       = 30 * time.Second
+	readHeaderTimeout = 10 * time.Second
+	idleTimeout       = 120 * time.Second
+	maxHeaderBytes    = "1MB"
+)
+
+// Configure set the server timeouts and limits
+func (c *Config) configure(s *http.Server) {
+	header := size(maxHeaderBytes)
+	if c.Limit != nil && size(c.Limit.Header) > 0 {
+		header = size(c.Limit.Header)
+	}
+	if c.Server == nil {
+		s.MaxHeaderBytes = int(header)
+		return
+	}
+	if size(c.Server.MaxHeaderBytes) > 0 {
+		header = size(c.Server.MaxHeaderBytes)
+	}
+	s.ReadTimeout = c.Server.ReadTimeout
+	s.ReadHeaderTimeout = c.Server.ReadHeaderTimeout
+	s.WriteTimeout = c.Server.WriteTimeout
+	s.IdleTimeout = c.Server.IdleTimeout
+	s.MaxHeaderBytes = int(header)
+	s.SetKeepAlivesEnabled(!c.Server.DisableKeepAlive)
+}
+
+// Listener limit the concurrent connections, new ones wait for a free slot
+func (c *Config) listener(l net.Listener) net.Listener {
+	if c.Server != nil && c.Server.MaxConns > 0 {
+		return netutil.LimitListener(l, c.Server.MaxConns)
+	}
+	return l
+}
@@ -0,0 +1,64 @@
+package fresh
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHTTPServer(t *testing.T) {
+	f := setup()
+	f.config.Host, f.config.Port, f.config.Banner = "127.0.0.1", 0, false
+	f.config.Limit = &Limit{Header: "2KB"}
+	f.config.Server.MaxConns = 1
+	if err := f.StartAsync(); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Stop()
+	if f.server.ReadHeaderTimeout != readHeaderTimeout || f.server.WriteTimeout != 0 || f.server.MaxHeaderBytes != 2048 {
+		t.Fatal("Unexpected server settings", f.server.ReadHeaderTimeout, f.server.WriteTimeout, f.server.MaxHeaderBytes)
+	}
+	// an idle connection hold the only slot
+	conn, err := net.Dial("tcp", f.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Timeout: 200 * time.Millisecond}
+	if _, err := client.Get("http://" + f.Addr().String()); err == nil {
+		t.Fatal("Expected the second connection to wait for a free slot")
+	}
+	conn.Close()
+	client.Timeout = 5 * time.Second
+	resp, err := client.Get("http://" + f.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestHTTPServer_Options(t *testing.T) {
+	f := setup()
+	f.config.Host, f.config.Port, f.config.Banner = "127.0.0.1", 0, false
+	f.config.Limit = &Limit{Header: "2KB"}
+	f.config.Server.MaxHeaderBytes = "1KB"
+	f.config.Server.DisableKeepAlive = true
+	f.GET("/", func(c Context) error {
+		return c.Response().Code(http.StatusOK)
+	})
+	if err := f.StartAsync(); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Stop()
+	if f.server.MaxHeaderBytes != 1024 {
+		t.Fatal("Expected the server max header bytes to override the limit", f.server.MaxHeaderBytes)
+	}
+	resp, err := http.Get("http://" + f.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if !resp.Close {
+		t.Fatal("Expected the connection to be closed", resp.Header)
+	}
+}
@@ -0,0 +1,207 @@
+package fresh
+
+import (
+	httpContext "context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+type (
+	// Shutdown config, the server waits up to timeout for the requests and the websockets to end
+	Shutdown struct {
+		Timeout time.Duration `yaml:"timeout,omitempty"`
+		Signals bool          `yaml:"signals,omitempty"` // stop on SIGINT and SIGTERM, disable to control the shutdown
+	}
+
+	// Lifecycle hooks and hijacked connections
+	lifecycle struct {
+		sync.Mutex
+		start    []func() error
+		shutdown []func(httpContext.Context) error
+		stop     []func() error
+		conns    map[*websocket.Conn]struct{}
+		active   sync.WaitGroup
+		stopping bool
+		once     sync.Once
+		done     chan struct{}
+		err      error
+		addr     net.Addr
+		serving  chan struct{}
+	}
+)
+
+// Websocket close status sent to the clients on shutdown
+const closeGoingAway = 1001
+
+// OnStart register a hook run before the server accept connections, an error abort the start
+func (f *fresh) OnStart(hook func() error) {
+	f.lifecycle.Lock()
+	defer f.lifecycle.Unlock()
+	f.lifecycle.start = append(f.lifecycle.start, hook)
+}
+
+// OnShutdown register a hook run when the shutdown begins, the context expires with the shutdown timeout
+func (f *fresh) OnShutdown(hook func(httpContext.Context) error) {
+	f.lifecycle.Lock()
+	defer f.lifecycle.Unlock()
+	f.lifecycle.shutdown = append(f.lifecycle.shutdown, hook)
+}
+
+// OnStop register a hook run once the requests and the websockets are drained
+func (f *fresh) OnStop(hook func() error) {
+	f.lifecycle.Lock()
+	defer f.lifecycle.Unlock()
+	f.lifecycle.stop = append(f.lifecycle.stop, hook)
+}
+
+// Track a websocket until its handler returns, false when the server is stopping
+func (l *lifecycle) track(ws *websocket.Conn) bool {
+	l.Lock()
+	defer l.Unlock()
+	if l.stopping {
+		return false
+	}
+	if l.conns == nil {
+		l.conns = make(map[*websocket.Conn]struct{})
+	}
+	l.conns[ws] = struct{}{}
+	l.active.Add(1)
+	return true
+}
+
+// Untrack a websocket whose handler returned
+func (l *lifecycle) untrack(ws *websocket.Conn) {
+	l.Lock()
+	delete(l.conns, ws)
+	l.Unlock()
+	l.active.Done()
+}
+
+// Drain close the websockets and wait for their handlers, up to the context deadline
+func (l *lifecycle) drain(ctx httpContext.Context) error {
+	l.Lock()
+	l.stopping = true
+	conns := make([]*websocket.Conn, 0, len(l.conns))
+	for ws := range l.conns {
+		conns = append(conns, ws)
+	}
+	l.Unlock()
+	deadline, _ := ctx.Deadline()
+	done := make(chan struct{})
+	go func() {
+		for _, ws := range conns {
+			goingAway(ws, deadline)
+		}
+		l.active.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// GoingAway close a websocket with the 1001 status, the expired deadline keep the default close frame off the wire.
+// The write deadline also ends a handler blocked writing to a slow client
+func goingAway(ws *websocket.Conn, deadline time.Time) {
+	if !deadline.IsZero() {
+		ws.SetWriteDeadline(deadline)
+	}
+	ws.WriteClose(closeGoingAway)
+	ws.SetDeadline(time.Now())
+	ws.Close()
+}
+
+// Timeout return the shutdown timeout, default 5s
+func (s *Shutdown) timeout() time.Duration {
+	if s == nil || s.Timeout <= 0 {
+		return 5 * time.Second
+	}
+	return s.Timeout
+}
+
+// Stop the server, hooks and websockets included, the first error is returned
+func (f *fresh) Stop() error {
+	f.lifecycle.once.Do(func() {
+		// fail the readiness probe while draining
+		atomic.StoreInt32(&f.health.stopping, 1)
+		// requests are still served while the load balancers see the failing probe
+		if h := f.config.Health; h != nil && h.Delay > 0 {
+			time.Sleep(h.Delay)
+		}
+		ctx, cancel := httpContext.WithTimeout(httpContext.Background(), f.config.Shutdown.timeout())
+		defer cancel()
+		f.lifecycle.Lock()
+		shutdown, stop, redirect := f.lifecycle.shutdown, f.lifecycle.stop, f.redirect
+		f.lifecycle.Unlock()
+		var errs []error
+		for _, hook := range shutdown {
+			errs = append(errs, hook(ctx))
+		}
+		if redirect != nil {
+			errs = append(errs, redirect.Shutdown(ctx))
+		}
+		errs = append(errs, f.server.Shutdown(ctx), f.lifecycle.drain(ctx))
+		// send the spans still queued by a batching exporter
+		if t := f.config.Tracing; t != nil {
+			if e, ok := t.Export.(interface {
+				Flush(httpContext.Context) error
+			}); ok {
+				errs = append(errs, e.Flush(ctx))
+			}
+		}
+		for _, hook := range stop {
+			errs = append(errs, hook())
+		}
+		for _, err := range errs {
+			if err == nil {
+				continue
+			}
+			if f.lifecycle.err == nil {
+				f.lifecycle.err = err
+			}
+			f.config.logger().Error("shutdown", "error", err)
+		}
+		f.config.logger().Info("server shutdown")
+		// the log files are closed last, after the shutdown is logged
+		if err := f.config.closeLogs(); err != nil && f.lifecycle.err == nil {
+			f.lifecycle.err = err
+		}
+		close(f.lifecycle.done)
+	})
+	return f.lifecycle.err
+}
+
+// Started run the start hooks
+func (f *fresh) started() error {
+	f.lifecycle.Lock()
+	start := f.lifecycle.start
+	f.lifecycle.Unlock()
+	for _, hook := range start {
+		if err := hook(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Listening save the bound address and signal the server is serving
+func (l *lifecycle) listening(addr net.Addr) {
+	l.Lock()
+	defer l.Unlock()
+	if l.addr == nil {
+		close(l.serving)
+	}
+	l.addr = addr
+}
+
+// NewLifecycle return the lifecycle of a server not yet started
+func newLifecycle() *lifecycle {
+	return &lifecycle{done: make(chan struct{}), serving: make(chan struct{})}
+}
@@ -0,0 +1,177 @@
+package fresh
+
+import (
+	"bytes"
+	httpContext "context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestStop(t *testing.T) {
+	f := setup()
+	f.config.Shutdown = &Shutdown{Timeout: time.Second}
+	var calls []string
+	closed := make(chan error, 1)
+	f.WS("/ws", func(c Context) error {
+		_, err := io.Copy(ioutil.Discard, c.Request().WS())
+		closed <- err
+		return err
+	})
+	server := httptest.NewServer(f.router)
+	defer server.Close()
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", "", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	failed := errors.New("flush failed")
+	f.OnShutdown(func(ctx httpContext.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Fatal("Expected a shutdown deadline")
+		}
+		calls = append(calls, "shutdown")
+		return failed
+	})
+	f.OnStop(func() error {
+		select {
+		case <-closed:
+		default:
+			t.Fatal("Expected the websocket to be drained before the stop hooks")
+		}
+		calls = append(calls, "stop")
+		return nil
+	})
+	if err := f.Stop(); err != failed {
+		t.Fatal("Expected the shutdown hook error instead of", err)
+	}
+	if strings.Join(calls, ",") != "shutdown,stop" {
+		t.Fatal("Unexpected hooks order", calls)
+	}
+	// stop is run once
+	if err := f.Stop(); err != failed || len(calls) != 2 {
+		t.Fatal("Expected a second stop to return the first result", err, calls)
+	}
+}
+
+// Connection recording the bytes read
+type recordConn struct {
+	net.Conn
+	read bytes.Buffer
+}
+
+func (c *recordConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.read.Write(b[:n])
+	return n, err
+}
+
+func TestStop_GoingAway(t *testing.T) {
+	f := setup()
+	f.WS("/ws", func(c Context) error {
+		_, err := io.Copy(ioutil.Discard, c.Request().WS())
+		return err
+	})
+	server := httptest.NewServer(f.router)
+	defer server.Close()
+	config, _ := websocket.NewConfig("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", server.URL)
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &recordConn{Conn: conn}
+	ws, err := websocket.NewClient(config, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	// wait for the handler to track the connection
+	tracked := func() int {
+		f.lifecycle.Lock()
+		defer f.lifecycle.Unlock()
+		return len(f.lifecycle.conns)
+	}
+	for i := 0; i < 100 && tracked() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	f.Stop()
+	io.Copy(ioutil.Discard, ws)
+	// unmasked close frame with the going away status and no other close frame
+	if frame := []byte{0x88, 0x02, 0x03, 0xe9}; !bytes.HasSuffix(rec.read.Bytes(), frame) {
+		t.Fatalf("Expected a going away close frame instead of % x", rec.read.Bytes())
+	}
+}
+
+func TestStop_SlowClient(t *testing.T) {
+	f := setup()
+	f.config.Shutdown = &Shutdown{Timeout: time.Second}
+	writing := make(chan struct{})
+	f.WS("/ws", func(c Context) error {
+		ws := c.Request().WS()
+		message := strings.Repeat("x", 1<<20)
+		close(writing)
+		for {
+			if err := websocket.Message.Send(ws, message); err != nil {
+				return err
+			}
+		}
+	})
+	server := httptest.NewServer(f.router)
+	defer server.Close()
+	// the client never read, the handler block once the socket buffers are full
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", "", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	<-writing
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now()
+	f.Stop()
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatal("Expected the shutdown timeout to bound the drain instead of", elapsed)
+	}
+}
+
+func TestStop_Logs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := setup()
+	f.config.Logs = Logs{File: true, Path: filepath.Join(dir, "fresh.logs")}
+	f.config.AccessLog = &AccessLog{Output: filepath.Join(dir, "access.logs")}
+	f.GET("/", func(c Context) error {
+		return c.Response().Code(http.StatusOK)
+	})
+	req, _ := http.NewRequest("GET", "/", nil)
+	f.router.ServeHTTP(httptest.NewRecorder(), req)
+	if err := f.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range []*rotateWriter{f.config.logFile, f.config.AccessLog.file} {
+		select {
+		case <-w.stopped:
+		default:
+			t.Fatal("Expected the log file to be closed by stop", w.path)
+		}
+		if w.file != nil {
+			t.Fatal("Expected the log file to be closed", w.path)
+		}
+	}
+	content, _ := ioutil.ReadFile(filepath.Join(dir, "fresh.logs"))
+	if !strings.Contains(string(content), "server shutdown") {
+		t.Fatal("Expected the shutdown to be logged before closing", string(content))
+	}
+}
@@ -0,0 +1,179 @@
+package fresh
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Certificate loaded from files, reloaded when they change
+type certificate struct {
+	sync.Mutex
+	config   *Config
+	crt, key string
+	every    time.Duration
+	cert     *tls.Certificate
+	modified time.Time
+	checked  time.Time
+}
+
+// TLS versions by name
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Modtime return the latest change of the certificate files
+func (c *certificate) modtime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{c.crt, c.key} {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return latest, err
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// Load the certificate files
+func (c *certificate) load() error {
+	modified, err := c.modtime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(c.crt, c.key)
+	if err != nil {
+		return err
+	}
+	c.cert, c.modified, c.checked = &cert, modified, time.Now()
+	return nil
+}
+
+// Get return the certificate, the files are checked for changes at most every reload interval
+func (c *certificate) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.every > 0 && time.Since(c.checked) >= c.every {
+		c.checked = time.Now()
+		if modified, err := c.modtime(); err == nil && modified.After(c.modified) {
+			// a broken update keeps the current certificate
+			if err := c.load(); err != nil {
+				c.config.logger().Error("tls certificate reload", "crt", c.crt, "error", err)
+			} else {
+				c.config.logger().Info("tls certificate reloaded", "crt", c.crt)
+			}
+		}
+	}
+	return c.cert, nil
+}
+
+// TSL return the tls config, certificate files when set otherwise an automatic certificate
+func (c *Config) tsl() (*tls.Config, error) {
+	t := c.TSL
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if t.MinVersion != "" {
+		version, ok := tlsVersions[t.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown tls version %q", t.MinVersion)
+		}
+		config.MinVersion = version
+	}
+	if len(t.Ciphers) > 0 {
+		ids, insecure := make(map[string]uint16), make(map[string]uint16)
+		for _, s := range tls.CipherSuites() {
+			ids[s.Name] = s.ID
+		}
+		for _, s := range tls.InsecureCipherSuites() {
+			insecure[s.Name] = s.ID
+		}
+		for _, name := range t.Ciphers {
+			id, ok := ids[name]
+			if !ok {
+				if id, ok = insecure[name]; ok && !t.Insecure {
+					return nil, fmt.Errorf("insecure tls cipher %q, enable insecure ciphers to use it", name)
+				}
+			}
+			if !ok {
+				return nil, fmt.Errorf("unknown tls cipher %q", name)
+			}
+			config.CipherSuites = append(config.CipherSuites, id)
+		}
+	}
+	if t.Crt != "" || t.Key != "" {
+		reload := t.Reload
+		if reload == 0 {
+			reload = 10 * time.Second
+		}
+		cert := &certificate{config: c, crt: t.Crt, key: t.Key, every: reload}
+		if err := cert.load(); err != nil {
+			return nil, err
+		}
+		config.GetCertificate = cert.get
+		return config, nil
+	}
+	t.manager = &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(c.Host),
+	}
+	if t.Cache != "" {
+		t.manager.Cache = autocert.DirCache(t.Cache)
+	}
+	config.GetCertificate = t.manager.GetCertificate
+	config.NextProtos = []string{"h2", "http/1.1", "acme-tls/1"}
+	return config, nil
+}
+
+// Redirect answer the plain http requests with a permanent redirect to https
+func (t *TSL) redirect(port string) http.Handler {
+	var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+	// automatic certificates are validated by http challenges
+	if t.manager != nil {
+		h = t.manager.HTTPHandler(h)
+	}
+	return h
+}
+
+// ServeRedirect start the http listener of a forced tls server
+func (f *fresh) serveRedirect(tlsAddr net.Addr) error {
+	addr := f.config.TSL.Redirect
+	if addr == "" {
+		addr = net.JoinHostPort(f.config.Host, "80")
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	_, port, _ := net.SplitHostPort(tlsAddr.String())
+	server := &http.Server{Handler: f.config.TSL.redirect(port)}
+	f.config.configure(server)
+	f.lifecycle.Lock()
+	f.redirect = server
+	f.lifecycle.Unlock()
+	f.config.logger().Info("server redirect", "addr", listener.Addr().String())
+	go func() {
+		if err := server.Serve(listener); err != http.ErrServerClosed {
+			f.config.logger().Error("server redirect", "error", err)
+		}
+	}()
+	return nil
+}
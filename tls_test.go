@@ -0,0 +1,146 @@
+package fresh
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Write a self signed certificate and its key
+func writeCert(t *testing.T, dir, name string) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := x509.MarshalECPrivateKey(key)
+	ioutil.WriteFile(filepath.Join(dir, "crt.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b}), 0600)
+}
+
+func TestTSL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeCert(t, dir, "first")
+	f := setup()
+	f.config.Host, f.config.Port, f.config.Banner = "127.0.0.1", 0, false
+	f.config.TSL = &TSL{Crt: filepath.Join(dir, "crt.pem"), Key: filepath.Join(dir, "key.pem"), MinVersion: "1.3", Reload: time.Nanosecond}
+	f.GET("/secure", func(c Context) error {
+		return c.Response().Raw(http.StatusOK, c.Request().Scheme())
+	})
+	if err := f.StartAsync(); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Stop()
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true, TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	get := func() *http.Response {
+		resp, err := client.Get("https://" + f.Addr().String() + "/secure")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	resp := get()
+	if resp.TLS.Version != tls.VersionTLS13 || resp.TLS.PeerCertificates[0].Subject.CommonName != "first" {
+		t.Fatal("Unexpected tls connection", resp.TLS.Version, resp.TLS.PeerCertificates[0].Subject)
+	}
+	// the new certificate is served without restart
+	writeCert(t, dir, "second")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(dir, "crt.pem"), later, later)
+	if resp = get(); resp.TLS.PeerCertificates[0].Subject.CommonName != "second" {
+		t.Fatal("Expected the reloaded certificate instead of", resp.TLS.PeerCertificates[0].Subject)
+	}
+}
+
+func TestTSL_Redirect(t *testing.T) {
+	tsl := &TSL{Force: true}
+	for port, location := range map[string]string{"443": "https://example.com/a?b=1", "8443": "https://example.com:8443/a?b=1"} {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://example.com:8080/a?b=1", nil)
+		tsl.redirect(port).ServeHTTP(rec, req)
+		if rec.Code != http.StatusMovedPermanently || rec.Header().Get(Location) != location {
+			t.Fatal("Unexpected redirect", rec.Code, rec.Header().Get(Location))
+		}
+	}
+}
+
+func TestTSL_Ciphers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeCert(t, dir, "ciphers")
+	c := &Config{TSL: &TSL{Crt: filepath.Join(dir, "crt.pem"), Key: filepath.Join(dir, "key.pem"), Ciphers: []string{"TLS_RSA_WITH_RC4_128_SHA"}}}
+	if _, err := c.tsl(); err == nil {
+		t.Fatal("Expected an insecure cipher error")
+	}
+	c.TSL.Insecure = true
+	if config, err := c.tsl(); err != nil || len(config.CipherSuites) != 1 {
+		t.Fatal("Expected the insecure cipher once enabled", err)
+	}
+}
+
+// Listener failing on accept
+type failListener struct {
+	net.Listener
+}
+
+func (l failListener) Accept() (net.Conn, error) {
+	return nil, errors.New("accept failed")
+}
+
+func TestTSL_RedirectShutdown(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeCert(t, dir, "redirect")
+	free, _ := net.Listen("tcp", "127.0.0.1:0")
+	redirect := free.Addr().String()
+	free.Close()
+	f := setup()
+	f.config.Banner = false
+	f.config.Server.ReadTimeout = time.Second
+	f.config.TSL = &TSL{Crt: filepath.Join(dir, "crt.pem"), Key: filepath.Join(dir, "key.pem"), Force: true, Redirect: redirect}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Serve(failListener{listener}); err == nil {
+		t.Fatal("Expected the accept error")
+	}
+	if f.redirect.ReadTimeout != time.Second || f.redirect.ReadHeaderTimeout != readHeaderTimeout {
+		t.Fatal("Expected the redirect server to use the server config", f.redirect.ReadTimeout, f.redirect.ReadHeaderTimeout)
+	}
+	if _, err := http.Get("http://" + redirect); err == nil {
+		t.Fatal("Expected the redirect server to be closed")
+	}
+}
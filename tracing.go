@@ -0,0 +1,447 @@
+package fresh
+
+import (
+	"bytes"
+	httpContext "context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	mathrand "math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Tracing config, a server span is created for each request and propagated with W3C trace context
+	Tracing struct {
+		once     sync.Once
+		Service  string       `yaml:"service,omitempty"`  // service name, default fresh
+		Sample   float64      `yaml:"sample,omitempty"`   // fraction of the new traces sampled, all when zero
+		Exporter string       `yaml:"exporter,omitempty"` // stdout or otlp
+		Endpoint string       `yaml:"endpoint,omitempty"` // otlp collector url, as http://localhost:4318/v1/traces
+		Export   SpanExporter `yaml:"-"`                  // custom exporter, overrides exporter and endpoint
+	}
+
+	// SpanExporter receive the sampled spans when they end
+	SpanExporter interface {
+		Export(*Span) error
+	}
+
+	// Span of a trace, the spans without a tracer are not recorded
+	Span struct {
+		mu         sync.Mutex
+		tracing    *Tracing
+		ended      bool
+		Service    string                 `json:"service"`
+		TraceID    string                 `json:"trace_id"`
+		SpanID     string                 `json:"span_id"`
+		ParentID   string                 `json:"parent_id,omitempty"`
+		TraceState string                 `json:"trace_state,omitempty"`
+		Sampled    bool                   `json:"-"`
+		Name       string                 `json:"name"`
+		Kind       string                 `json:"kind"`
+		StartTime  time.Time              `json:"start"`
+		EndTime    time.Time              `json:"end"`
+		Attributes map[string]interface{} `json:"attributes,omitempty"`
+		Error      string                 `json:"error,omitempty"`
+	}
+
+	// Write the spans as json lines
+	stdoutExporter struct {
+		mu  sync.Mutex
+		out io.Writer
+	}
+
+	// OTLPExporter send the spans in batches to an OTLP/HTTP collector with the json encoding
+	OTLPExporter struct {
+		once     sync.Once
+		mu       sync.Mutex
+		spans    []*Span
+		full     chan struct{}
+		flush    chan otlpFlush
+		Endpoint string
+		Headers  map[string]string
+		Client   *http.Client
+		Batch    int
+		Interval time.Duration
+	}
+
+	// Flush request handled by the sending loop
+	otlpFlush struct {
+		ctx  httpContext.Context
+		done chan error
+	}
+
+	spanKey struct{}
+)
+
+// Span kinds
+const (
+	SpanServer   = "server"
+	SpanInternal = "internal"
+)
+
+// SpanFromContext return the span stored in a Go context, nil if missing
+func SpanFromContext(ctx httpContext.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// StartSpan start a child of the span stored in a Go context, end it to export it
+func StartSpan(ctx httpContext.Context, name string) (httpContext.Context, *Span) {
+	span := &Span{Name: name, Kind: SpanInternal, StartTime: time.Now(), SpanID: randomHex(8)}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.tracing, span.Service = parent.tracing, parent.Service
+		span.TraceID, span.ParentID = parent.TraceID, parent.SpanID
+		span.TraceState, span.Sampled = parent.TraceState, parent.Sampled
+	} else {
+		span.TraceID = randomHex(16)
+	}
+	return httpContext.WithValue(ctx, spanKey{}, span), span
+}
+
+// SetAttribute add an attribute to the span
+func (s *Span) SetAttribute(k string, v interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]interface{})
+	}
+	s.Attributes[k] = v
+}
+
+// SetError mark the span as failed
+func (s *Span) SetError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.Error = err.Error()
+	s.mu.Unlock()
+}
+
+// TraceParent return the traceparent header value of the span
+func (s *Span) TraceParent() string {
+	flags := "00"
+	if s.Sampled {
+		flags = "01"
+	}
+	return "00-" + s.TraceID + "-" + s.SpanID + "-" + flags
+}
+
+// End the span and export it if sampled, only the first call has effect
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.EndTime = time.Now()
+	s.mu.Unlock()
+	if s.Sampled && s.tracing != nil {
+		s.tracing.exporter().Export(s)
+	}
+}
+
+// Exporter return the span exporter, built from the config on first use
+func (t *Tracing) exporter() SpanExporter {
+	t.once.Do(func() {
+		if t.Service == "" {
+			t.Service = "fresh"
+		}
+		if t.Export != nil {
+			return
+		}
+		if strings.EqualFold(t.Exporter, "otlp") {
+			t.Export = NewOTLPExporter(t.Endpoint)
+			return
+		}
+		t.Export = NewStdoutExporter(nil)
+	})
+	return t.Export
+}
+
+// ParseTraceParent parse a W3C traceparent header, ok is false when it's invalid
+func parseTraceParent(h string) (traceID, parentID string, sampled, ok bool) {
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return
+	}
+	traceID, parentID = parts[1], parts[2]
+	if len(traceID) != 32 || len(parentID) != 16 || len(parts[3]) != 2 || !lowerHex(parts[0]+traceID+parentID+parts[3]) {
+		return
+	}
+	if traceID == strings.Repeat("0", 32) || parentID == strings.Repeat("0", 16) {
+		return
+	}
+	flags, _ := strconv.ParseUint(parts[3], 16, 8)
+	return traceID, parentID, flags&1 == 1, true
+}
+
+// LowerHex check a string is made of lowercase hex digits
+func lowerHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// RandomHex return n random bytes hex encoded
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Start the server span of a request, continuing the incoming trace if any
+func (t *Tracing) start(c *context, r *http.Request) *http.Request {
+	t.exporter()
+	span := &Span{tracing: t, Service: t.Service, Kind: SpanServer, StartTime: time.Now(), SpanID: randomHex(8), Name: r.Method}
+	if traceID, parentID, sampled, ok := parseTraceParent(r.Header.Get(Traceparent)); ok {
+		span.TraceID, span.ParentID, span.Sampled = traceID, parentID, sampled
+		span.TraceState = strings.TrimSpace(strings.Join(r.Header.Values(Tracestate), ","))
+	} else {
+		span.TraceID = randomHex(16)
+		span.Sampled = t.Sample <= 0 || t.Sample >= 1 || mathrand.Float64() < t.Sample
+	}
+	c.Set(ContextSpan, span)
+	return r.WithContext(httpContext.WithValue(r.Context(), spanKey{}, span))
+}
+
+// End the server span, named after the route pattern
+func (t *Tracing) end(c *context, w *accessWriter) {
+	span, ok := c.Get(ContextSpan).(*Span)
+	if !ok {
+		return
+	}
+	r := c.request.r
+	if c.handler != nil && c.handler.route != nil {
+		route := c.handler.route.pattern()
+		span.Name = r.Method + " " + route
+		span.SetAttribute("http.route", route)
+	}
+	status := w.status()
+	span.SetAttribute("http.request.method", r.Method)
+	span.SetAttribute("http.response.status_code", status)
+	span.SetAttribute("url.path", r.URL.Path)
+	span.SetAttribute("client.address", c.request.RealIP())
+	if status >= http.StatusInternalServerError {
+		span.SetError(errors.New(http.StatusText(status)))
+	}
+	span.End()
+}
+
+// NewStdoutExporter return an exporter writing the spans as json lines, on stdout when out is nil
+func NewStdoutExporter(out io.Writer) SpanExporter {
+	if out == nil {
+		out = os.Stdout
+	}
+	return &stdoutExporter{out: out}
+}
+
+// Export write a span as a json line
+func (e *stdoutExporter) Export(s *Span) error {
+	s.mu.Lock()
+	b, err := json.Marshal(s)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err = e.out.Write(append(b, '\n'))
+	return err
+}
+
+// NewOTLPExporter return an exporter sending batches of spans to an OTLP/HTTP collector
+func NewOTLPExporter(endpoint string) *OTLPExporter {
+	if endpoint == "" {
+		endpoint = "http://localhost:4318/v1/traces"
+	}
+	return &OTLPExporter{Endpoint: endpoint, Batch: 512, Interval: 5 * time.Second}
+}
+
+// Init start the sending loop on first use
+func (e *OTLPExporter) init() {
+	e.once.Do(func() {
+		if e.Client == nil {
+			e.Client = &http.Client{Timeout: 10 * time.Second}
+		}
+		e.full = make(chan struct{}, 1)
+		e.flush = make(chan otlpFlush)
+		go e.loop()
+	})
+}
+
+// Export queue a span, the batch is sent when full or every interval
+func (e *OTLPExporter) Export(s *Span) error {
+	e.init()
+	e.mu.Lock()
+	e.spans = append(e.spans, s)
+	full := e.Batch > 0 && len(e.spans) >= e.Batch
+	e.mu.Unlock()
+	if full {
+		// wake the loop without waiting, a pending signal already cover this batch
+		select {
+		case e.full <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Flush send the queued spans and wait for the collector answer, until the context is done
+func (e *OTLPExporter) Flush(ctx httpContext.Context) error {
+	e.init()
+	done := make(chan error, 1)
+	select {
+	case e.flush <- otlpFlush{ctx, done}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Loop send the queued spans every interval or on flush
+func (e *OTLPExporter) loop() {
+	interval := e.Interval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.send(httpContext.Background())
+		case <-e.full:
+			e.send(httpContext.Background())
+		case f := <-e.flush:
+			f.done <- e.send(f.ctx)
+		}
+	}
+}
+
+// Send post the queued spans to the collector
+func (e *OTLPExporter) send(ctx httpContext.Context) error {
+	e.mu.Lock()
+	spans := e.spans
+	e.spans = nil
+	e.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(otlpPayload(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(ContentType, MIMEAppJSON)
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("otlp collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// OtlpPayload convert the spans to an OTLP trace request, grouped by service
+func otlpPayload(spans []*Span) map[string]interface{} {
+	services := make(map[string][]interface{})
+	for _, s := range spans {
+		s.mu.Lock()
+		kind := 1
+		if s.Kind == SpanServer {
+			kind = 2
+		}
+		status := map[string]interface{}{"code": 0}
+		if s.Error != "" {
+			status = map[string]interface{}{"code": 2, "message": s.Error}
+		}
+		keys := make([]string, 0, len(s.Attributes))
+		for k := range s.Attributes {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		attributes := make([]interface{}, 0, len(keys))
+		for _, k := range keys {
+			attributes = append(attributes, otlpAttribute(k, s.Attributes[k]))
+		}
+		span := map[string]interface{}{
+			"traceId":           s.TraceID,
+			"spanId":            s.SpanID,
+			"name":              s.Name,
+			"kind":              kind,
+			"startTimeUnixNano": strconv.FormatInt(s.StartTime.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.EndTime.UnixNano(), 10),
+			"attributes":        attributes,
+			"status":            status,
+		}
+		if s.ParentID != "" {
+			span["parentSpanId"] = s.ParentID
+		}
+		if s.TraceState != "" {
+			span["traceState"] = s.TraceState
+		}
+		services[s.Service] = append(services[s.Service], span)
+		s.mu.Unlock()
+	}
+	resources := make([]interface{}, 0, len(services))
+	for service, list := range services {
+		resources = append(resources, map[string]interface{}{
+			"resource":   map[string]interface{}{"attributes": []interface{}{otlpAttribute("service.name", service)}},
+			"scopeSpans": []interface{}{map[string]interface{}{"scope": map[string]interface{}{"name": "fresh"}, "spans": list}},
+		})
+	}
+	return map[string]interface{}{"resourceSpans": resources}
+}
+
+// OtlpAttribute convert an attribute to an OTLP key value
+func otlpAttribute(k string, v interface{}) map[string]interface{} {
+	var value map[string]interface{}
+	switch v := v.(type) {
+	case bool:
+		value = map[string]interface{}{"boolValue": v}
+	case int:
+		value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+	case int64:
+		value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		} else {
+			value = map[string]interface{}{"doubleValue": v}
+		}
+	default:
+		value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+	}
+	return map[string]interface{}{"key": k, "value": value}
+}
@@ -0,0 +1,143 @@
+package fresh
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTracing(t *testing.T) {
+	buf := new(bytes.Buffer)
+	f := setup()
+	f.config.Tracing = &Tracing{Export: NewStdoutExporter(buf)}
+	f.GET("/items/:id", func(c Context) error {
+		_, child := StartSpan(c.Request().Get().Context(), "query")
+		child.End()
+		return c.Response().Code(http.StatusOK)
+	})
+	req, _ := http.NewRequest("GET", "/items/1", nil)
+	req.Header.Set(Traceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(Tracestate, "vendor=value")
+	f.router.ServeHTTP(httptest.NewRecorder(), req)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatal("Expected 2 spans instead of", buf.String())
+	}
+	var child, server Span
+	json.Unmarshal([]byte(lines[0]), &child)
+	json.Unmarshal([]byte(lines[1]), &server)
+	if server.Name != "GET /items/:id" || server.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || server.ParentID != "00f067aa0ba902b7" || server.TraceState != "vendor=value" {
+		t.Fatal("Unexpected server span", lines[1])
+	}
+	if server.Attributes["http.response.status_code"] != float64(http.StatusOK) || server.Attributes["http.request.method"] != "GET" {
+		t.Fatal("Unexpected server span attributes", lines[1])
+	}
+	if child.TraceID != server.TraceID || child.ParentID != server.SpanID || child.Kind != SpanInternal {
+		t.Fatal("Unexpected child span", lines[0])
+	}
+	// an invalid or unsampled parent isn't exported
+	buf.Reset()
+	req.Header.Set(Traceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	f.router.ServeHTTP(httptest.NewRecorder(), req)
+	if buf.Len() != 0 {
+		t.Fatal("Expected unsampled spans to be dropped", buf.String())
+	}
+	if _, _, _, ok := parseTraceParent("00-00000000000000000000000000000000-00f067aa0ba902b7-01"); ok {
+		t.Fatal("Expected a zero trace id to be invalid")
+	}
+}
+
+func TestTracing_OTLP(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies <- b
+	}))
+	defer collector.Close()
+	exporter := NewOTLPExporter(collector.URL + "/v1/traces")
+	f := setup()
+	f.config.Tracing = &Tracing{Service: "api", Export: exporter}
+	f.GET("/fail", func(c Context) error {
+		return c.Response().Code(http.StatusInternalServerError)
+	})
+	req, _ := http.NewRequest("GET", "/fail", nil)
+	f.router.ServeHTTP(httptest.NewRecorder(), req)
+	// the queued spans are sent on shutdown
+	if err := f.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	var payload struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []struct {
+					Key   string
+					Value map[string]interface{}
+				}
+			}
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID string `json:"traceId"`
+					Name    string
+					Kind    int
+					Status  struct {
+						Code int
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(<-bodies, &payload); err != nil {
+		t.Fatal(err)
+	}
+	rs := payload.ResourceSpans
+	if len(rs) != 1 || rs[0].Resource.Attributes[0].Value["stringValue"] != "api" || len(rs[0].ScopeSpans[0].Spans) != 1 {
+		t.Fatal("Unexpected otlp payload", payload)
+	}
+	span := rs[0].ScopeSpans[0].Spans[0]
+	if span.Name != "GET /fail" || span.Kind != 2 || span.Status.Code != 2 || len(span.TraceID) != 32 {
+		t.Fatal("Unexpected otlp span", span)
+	}
+	// a full batch is sent without a flush
+	batched := NewOTLPExporter(collector.URL + "/v1/traces")
+	batched.Batch = 2
+	for i := 0; i < 2; i++ {
+		batched.Export(&Span{Name: "span"})
+	}
+	select {
+	case <-bodies:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the full batch to be sent")
+	}
+}
+
+func TestTracing_OTLPTimeout(t *testing.T) {
+	release := make(chan struct{})
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer collector.Close()
+	defer close(release)
+	f := setup()
+	f.config.Shutdown = &Shutdown{Timeout: 100 * time.Millisecond}
+	f.config.Tracing = &Tracing{Export: NewOTLPExporter(collector.URL + "/v1/traces")}
+	f.GET("/", func(c Context) error {
+		return c.Response().Code(http.StatusOK)
+	})
+	req, _ := http.NewRequest("GET", "/", nil)
+	f.router.ServeHTTP(httptest.NewRecorder(), req)
+	start := time.Now()
+	if err := f.Stop(); err == nil {
+		t.Fatal("Expected the flush to fail with the shutdown deadline")
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatal("Expected the flush to stop at the shutdown deadline, took", d)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/fatih/color"
 	"math/rand"
 	"net"
+	"net/http"
 	"regexp"
 )
 
@@ -32,22 +33,25 @@ func randPort(address string, port int) int {
 
 // Size convert a string like 10K or 5MB in relative int64 number size
 func size(s string) (r int64) {
-	format := regexp.MustCompile("[A-Z]+")
-	num, err := strconv.ParseInt(regexp.MustCompile("[0-9]+").String(), 10, 64)
+	match := regexp.MustCompile(`^\s*([0-9]+)\s*([a-zA-Z]*)\s*$`).FindStringSubmatch(s)
+	if match == nil {
+		return
+	}
+	num, err := strconv.ParseInt(match[1], 10, 64)
 	if err != nil {
 		return
 	}
-	switch format.String() {
-	case "B", "b":
+	switch strings.ToUpper(match[2]) {
+	case "", "B":
 		return num * B
-	case "KB", "K", "kb", "k":
+	case "KB", "K":
 		return num * K
-	case "MB", "M", "mb", "m":
+	case "MB", "M":
 		return num * M
-	case "GB", "G", "gb", "g":
-		return num * G
-	case "TB", "T", "tb", "t":
+	case "GB", "G":
 		return num * G
+	case "TB", "T":
+		return num * T
 	}
 	return
 }
@@ -63,6 +67,56 @@ func contain(s string, arr []string) bool {
 	return false
 }
 
+// Vary add the given request headers to the response Vary header, avoiding duplicates
+func vary(h http.Header, keys ...string) {
+	for _, k := range keys {
+		found := false
+		for _, v := range h[Vary] {
+			for _, field := range strings.Split(v, ",") {
+				if strings.EqualFold(strings.TrimSpace(field), k) {
+					found = true
+				}
+			}
+		}
+		if !found {
+			h.Add(Vary, k)
+		}
+	}
+}
+
+// QuoteETag return an entity tag wrapped in double quotes
+func quoteETag(tag string) string {
+	if strings.HasPrefix(tag, "\"") || strings.HasPrefix(tag, "W/\"") {
+		return tag
+	}
+	return "\"" + tag + "\""
+}
+
+// MatchETag check if an entity tag is in a If-Match or If-None-Match list, weak comparison ignore the W/ prefix
+func matchETag(list, tag string, weak bool) bool {
+	if tag == "" {
+		return false
+	}
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	if weak {
+		tag = strings.TrimPrefix(tag, "W/")
+	} else if strings.HasPrefix(tag, "W/") {
+		return false
+	}
+	for _, t := range strings.Split(list, ",") {
+		t = strings.TrimSpace(t)
+		if weak {
+			t = strings.TrimPrefix(t, "W/")
+		}
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
 // Print the list of routes
 func PrintRouter(r *router) {
 	var tree func(routes []*route, parentPath string) error
@@ -84,6 +138,10 @@ func PrintRouter(r *router) {
 					print("-")
 				}
 				print(">")
+				if len(handler.require) > 0 {
+					println(currentPath, "["+strings.Join(handler.require, " ")+"]")
+					continue
+				}
 				println(currentPath)
 			}
 			tree(route.children, currentPath)